@@ -10,25 +10,32 @@ import (
 )
 
 type ExampleKubevirtOptions struct {
-	ServicePublishingStrategy  string
-	APIServerAddress           string
-	Memory                     string
-	Cores                      uint32
-	Image                      string
-	RootVolumeSize             uint32
-	RootVolumeStorageClass     string
-	RootVolumeAccessModes      string
-	RootVolumeVolumeMode       string
-	BaseDomainPassthrough      bool
-	InfraKubeConfig            []byte
-	InfraNamespace             string
-	CacheStrategyType          string
-	InfraStorageClassMappings  []string
-	NetworkInterfaceMultiQueue *hyperv1.MultiQueueSetting
-	QoSClass                   *hyperv1.QoSClass
+	ServicePublishingStrategy    string
+	APIServerAddress             string
+	Memory                       string
+	Cores                        uint32
+	Image                        string
+	RootVolumeSize               uint32
+	RootVolumeStorageClass       string
+	RootVolumeAccessModes        string
+	RootVolumeVolumeMode         string
+	BaseDomainPassthrough        bool
+	InfraKubeConfig              []byte
+	InfraNamespace               string
+	CacheStrategyType            string
+	InfraStorageClassMappings    []string
+	InfraStorageClassEnforcement *hyperv1.InfraStorageClassEnforcement
+	InfraClusterLabels           map[string]string
+	NetworkInterfaceMultiQueue   *hyperv1.MultiQueueSetting
+	QoSClass                     *hyperv1.QoSClass
+	MemoryRequest                string
+	CPURequest                   uint32
 }
 
-func ExampleKubeVirtTemplate(o *ExampleKubevirtOptions) *hyperv1.KubevirtNodePoolPlatform {
+// ExampleKubeVirtTemplate builds a KubevirtNodePoolPlatform from o. It returns an error rather
+// than a silently-invalid template when a Compute setting would leave the VMI without a usable
+// QoS class, e.g. Guaranteed with no Memory/Cores, or Burstable with no limits at all.
+func ExampleKubeVirtTemplate(o *ExampleKubevirtOptions) (*hyperv1.KubevirtNodePoolPlatform, error) {
 	var storageClassName *string
 	var accessModesStr []string
 	var accessModes []hyperv1.PersistentVolumeAccessMode
@@ -73,9 +80,40 @@ func ExampleKubeVirtTemplate(o *ExampleKubevirtOptions) *hyperv1.KubevirtNodePoo
 	if o.Cores != 0 {
 		exampleTemplate.Compute.Cores = &o.Cores
 	}
+	if o.MemoryRequest != "" {
+		memoryRequest := apiresource.MustParse(o.MemoryRequest)
+		exampleTemplate.Compute.MemoryRequest = &memoryRequest
+	}
+	if o.CPURequest != 0 {
+		exampleTemplate.Compute.CPURequest = &o.CPURequest
+	}
 
-	if o.QoSClass != nil && *o.QoSClass == hyperv1.QoSClassGuaranteed {
-		exampleTemplate.Compute.QosClass = o.QoSClass
+	if o.QoSClass != nil {
+		switch *o.QoSClass {
+		case hyperv1.QoSClassGuaranteed:
+			if exampleTemplate.Compute.Memory == nil && exampleTemplate.Compute.Cores == nil {
+				return nil, fmt.Errorf("QoSClass Guaranteed requires Memory and/or Cores to be set, or the VMI would have no resource requests or limits at all")
+			}
+			exampleTemplate.Compute.QosClass = o.QoSClass
+		case hyperv1.QoSClassBurstable:
+			// MemoryLimit/CPULimit are derived from Memory/Cores below, so at least one of those
+			// (not CPURequest/MemoryRequest, which only ever become requests) must be set or the
+			// VMI would have no limit at all and land in the BestEffort QoS class.
+			if o.Memory == "" && o.Cores == 0 {
+				return nil, fmt.Errorf("QoSClass Burstable requires Memory and/or Cores to be set so at least one of MemoryLimit/CPULimit is populated, or the VMI would land in the BestEffort QoS class")
+			}
+			exampleTemplate.Compute.QosClass = o.QoSClass
+			if exampleTemplate.Compute.Memory != nil {
+				memoryLimit := *exampleTemplate.Compute.Memory
+				exampleTemplate.Compute.MemoryLimit = &memoryLimit
+			}
+			if exampleTemplate.Compute.Cores != nil {
+				cpuLimit := *exampleTemplate.Compute.Cores
+				exampleTemplate.Compute.CPULimit = &cpuLimit
+			}
+		default:
+			return nil, fmt.Errorf("unsupported QoSClass %q", *o.QoSClass)
+		}
 	}
 
 	if o.Image != "" {
@@ -95,5 +133,5 @@ func ExampleKubeVirtTemplate(o *ExampleKubevirtOptions) *hyperv1.KubevirtNodePoo
 		exampleTemplate.NetworkInterfaceMultiQueue = o.NetworkInterfaceMultiQueue
 	}
 
-	return exampleTemplate
+	return exampleTemplate, nil
 }
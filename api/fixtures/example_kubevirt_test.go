@@ -0,0 +1,50 @@
+package fixtures
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	hyperv1 "github.com/openshift/hypershift/api/v1beta1"
+)
+
+func TestExampleKubeVirtTemplateBurstable(t *testing.T) {
+	burstable := hyperv1.QoSClassBurstable
+
+	t.Run("CPURequest alone is rejected: it would leave no MemoryLimit/CPULimit", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := ExampleKubeVirtTemplate(&ExampleKubevirtOptions{
+			RootVolumeSize: 32,
+			QoSClass:       &burstable,
+			CPURequest:     2,
+		})
+		g.Expect(err).To(HaveOccurred())
+	})
+
+	t.Run("Cores alone is accepted and populates CPULimit", func(t *testing.T) {
+		g := NewWithT(t)
+		platform, err := ExampleKubeVirtTemplate(&ExampleKubevirtOptions{
+			RootVolumeSize: 32,
+			QoSClass:       &burstable,
+			Cores:          4,
+			CPURequest:     2,
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(platform.Compute.CPULimit).ToNot(BeNil())
+		g.Expect(*platform.Compute.CPULimit).To(Equal(uint32(4)))
+		g.Expect(platform.Compute.MemoryLimit).To(BeNil())
+	})
+
+	t.Run("Memory alone is accepted and populates MemoryLimit", func(t *testing.T) {
+		g := NewWithT(t)
+		platform, err := ExampleKubeVirtTemplate(&ExampleKubevirtOptions{
+			RootVolumeSize: 32,
+			QoSClass:       &burstable,
+			Memory:         "4Gi",
+			MemoryRequest:  "2Gi",
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(platform.Compute.MemoryLimit).ToNot(BeNil())
+		g.Expect(platform.Compute.CPULimit).To(BeNil())
+	})
+}
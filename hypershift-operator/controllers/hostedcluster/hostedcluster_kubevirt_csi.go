@@ -0,0 +1,168 @@
+package hostedcluster
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/openshift/hypershift/api/v1beta1"
+)
+
+const (
+	// kubevirtCSIDriverContainerName is the kubevirt-csi-driver controller container in the CSI
+	// driver Deployment reconciled onto the management cluster for a KubeVirt HostedCluster.
+	kubevirtCSIDriverContainerName = "csi-driver"
+
+	// kubevirtCSIInfraClusterLabelsFlag is the kubevirt-csi-driver controller flag that tells it
+	// which labels to stamp onto every tenant PVC it provisions on the shared infra cluster, and
+	// to refuse binding any infra PVC that doesn't already carry them (GHSA-fg9q-5cw2-p6r9).
+	kubevirtCSIInfraClusterLabelsFlag = "--infra-cluster-labels"
+
+	// kubevirtCSIStorageClassEnforcementConfigMapKey is the key under which the marshaled
+	// InfraStorageClassEnforcement policy is injected into the kubevirt-csi-driver deployment, replacing
+	// the previous opaque INFRA_STORAGE_CLASS_ENFORCEMENT env var.
+	kubevirtCSIStorageClassEnforcementConfigMapKey = "storage-class-enforcement.yaml"
+
+	// kubevirtCSIStorageClassEnforcementVolumeName and kubevirtCSIStorageClassEnforcementMountPath
+	// are the volume/mount the enforcement policy ConfigMap is projected onto in the csi-driver
+	// container.
+	kubevirtCSIStorageClassEnforcementVolumeName = "storage-class-enforcement"
+	kubevirtCSIStorageClassEnforcementMountPath  = "/etc/kubevirt-csi-driver"
+
+	// kubevirtCSIStorageClassEnforcementConfigFlag points the csi-driver controller at the
+	// mounted enforcement policy file.
+	kubevirtCSIStorageClassEnforcementConfigFlag = "--storage-class-enforcement-config"
+)
+
+// kubevirtCSIStorageClassEnforcementYAML marshals the HostedCluster's InfraStorageClassEnforcement
+// policy so it can be mounted into the kubevirt-csi-driver deployment. A nil policy marshals to the
+// YAML document "null", which the CSI driver treats as allow-all.
+func kubevirtCSIStorageClassEnforcementYAML(platform *v1beta1.KubevirtPlatformSpec) ([]byte, error) {
+	out, err := yaml.Marshal(platform.InfraStorageClassEnforcement)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal InfraStorageClassEnforcement: %w", err)
+	}
+	return out, nil
+}
+
+// kubevirtCSIInfraClusterLabelsValue renders a HostedCluster's InfraClusterLabels as the
+// comma-separated key=value list the kubevirt-csi-driver controller's --infra-cluster-labels flag
+// expects. Keys are sorted so the rendered deployment doesn't flap on every reconcile.
+func kubevirtCSIInfraClusterLabelsValue(platform *v1beta1.KubevirtPlatformSpec) string {
+	keys := make([]string, 0, len(platform.InfraClusterLabels))
+	for k := range platform.InfraClusterLabels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", k, platform.InfraClusterLabels[k]))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// ReconcileKubevirtCSIStorageClassEnforcementConfigMap sets the ConfigMap data the
+// kubevirt-csi-driver deployment mounts its InfraStorageClassEnforcement policy from.
+func ReconcileKubevirtCSIStorageClassEnforcementConfigMap(cm *corev1.ConfigMap, platform *v1beta1.KubevirtPlatformSpec) error {
+	policyYAML, err := kubevirtCSIStorageClassEnforcementYAML(platform)
+	if err != nil {
+		return err
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[kubevirtCSIStorageClassEnforcementConfigMapKey] = string(policyYAML)
+	return nil
+}
+
+// ReconcileKubevirtCSIDriverDeployment threads a KubeVirt HostedCluster's platform config into the
+// kubevirt-csi-driver controller container: the --infra-cluster-labels the controller uses to
+// stamp newly-provisioned tenant PVCs on the shared infra cluster with the same labels
+// validateInfraClusterLabels requires on the HostedCluster, and to refuse binding any infra PVC
+// that doesn't already carry them (GHSA-fg9q-5cw2-p6r9); and, via
+// storageClassEnforcementConfigMap, the mounted InfraStorageClassEnforcement policy the
+// controller's --storage-class-enforcement-config flag points at.
+func ReconcileKubevirtCSIDriverDeployment(deployment *appsv1.Deployment, platform *v1beta1.KubevirtPlatformSpec, storageClassEnforcementConfigMap string) error {
+	container := findCSIDriverContainer(deployment)
+	if container == nil {
+		return fmt.Errorf("deployment %s/%s has no %q container", deployment.Namespace, deployment.Name, kubevirtCSIDriverContainerName)
+	}
+
+	container.Args = setFlagValue(container.Args, kubevirtCSIInfraClusterLabelsFlag, kubevirtCSIInfraClusterLabelsValue(platform))
+
+	if storageClassEnforcementConfigMap == "" {
+		return nil
+	}
+
+	container.Args = setFlagValue(container.Args, kubevirtCSIStorageClassEnforcementConfigFlag, kubevirtCSIStorageClassEnforcementMountPath+"/"+kubevirtCSIStorageClassEnforcementConfigMapKey)
+
+	podSpec := &deployment.Spec.Template.Spec
+	volumeSource := corev1.VolumeSource{
+		ConfigMap: &corev1.ConfigMapVolumeSource{
+			LocalObjectReference: corev1.LocalObjectReference{Name: storageClassEnforcementConfigMap},
+		},
+	}
+	volumeFound := false
+	for i := range podSpec.Volumes {
+		if podSpec.Volumes[i].Name == kubevirtCSIStorageClassEnforcementVolumeName {
+			podSpec.Volumes[i].VolumeSource = volumeSource
+			volumeFound = true
+			break
+		}
+	}
+	if !volumeFound {
+		podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+			Name:         kubevirtCSIStorageClassEnforcementVolumeName,
+			VolumeSource: volumeSource,
+		})
+	}
+
+	mountFound := false
+	for i := range container.VolumeMounts {
+		if container.VolumeMounts[i].Name == kubevirtCSIStorageClassEnforcementVolumeName {
+			container.VolumeMounts[i].MountPath = kubevirtCSIStorageClassEnforcementMountPath
+			mountFound = true
+			break
+		}
+	}
+	if !mountFound {
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      kubevirtCSIStorageClassEnforcementVolumeName,
+			MountPath: kubevirtCSIStorageClassEnforcementMountPath,
+		})
+	}
+
+	return nil
+}
+
+// findCSIDriverContainer returns the kubevirt-csi-driver controller container in deployment, or
+// nil if it isn't present.
+func findCSIDriverContainer(deployment *appsv1.Deployment) *corev1.Container {
+	containers := deployment.Spec.Template.Spec.Containers
+	for i := range containers {
+		if containers[i].Name == kubevirtCSIDriverContainerName {
+			return &containers[i]
+		}
+	}
+	return nil
+}
+
+// setFlagValue sets "flag=value" in args, replacing an existing "flag=..." entry in place if one
+// is already present and appending otherwise.
+func setFlagValue(args []string, flag, value string) []string {
+	entry := fmt.Sprintf("%s=%s", flag, value)
+	prefix := flag + "="
+	for i, arg := range args {
+		if strings.HasPrefix(arg, prefix) {
+			args[i] = entry
+			return args
+		}
+	}
+	return append(args, entry)
+}
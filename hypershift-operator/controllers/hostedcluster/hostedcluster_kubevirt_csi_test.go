@@ -0,0 +1,110 @@
+package hostedcluster
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift/hypershift/api/v1beta1"
+)
+
+func newCSIDriverDeployment() *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "kubevirt-csi-driver", Namespace: "clusters-guest"},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{Name: kubevirtCSIDriverContainerName},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestReconcileKubevirtCSIDriverDeploymentInfraClusterLabels(t *testing.T) {
+	g := NewWithT(t)
+
+	platform := &v1beta1.KubevirtPlatformSpec{
+		InfraClusterLabels: map[string]string{"tenant": "cluster-under-test", "team": "hypershift"},
+	}
+
+	deployment := newCSIDriverDeployment()
+	g.Expect(ReconcileKubevirtCSIDriverDeployment(deployment, platform, "")).To(Succeed())
+
+	container := findCSIDriverContainer(deployment)
+	g.Expect(container).ToNot(BeNil())
+	g.Expect(container.Args).To(ContainElement("--infra-cluster-labels=team=hypershift,tenant=cluster-under-test"))
+
+	// Reconciling again must update the flag in place rather than appending a duplicate.
+	g.Expect(ReconcileKubevirtCSIDriverDeployment(deployment, platform, "")).To(Succeed())
+	g.Expect(container.Args).To(HaveLen(1))
+}
+
+func TestReconcileKubevirtCSIDriverDeploymentMissingContainer(t *testing.T) {
+	g := NewWithT(t)
+
+	deployment := &appsv1.Deployment{}
+	err := ReconcileKubevirtCSIDriverDeployment(deployment, &v1beta1.KubevirtPlatformSpec{}, "")
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestReconcileKubevirtCSIDriverDeploymentStorageClassEnforcement(t *testing.T) {
+	g := NewWithT(t)
+
+	platform := &v1beta1.KubevirtPlatformSpec{
+		InfraStorageClassEnforcement: &v1beta1.InfraStorageClassEnforcement{
+			AllowList: []string{"fast"},
+		},
+	}
+
+	deployment := newCSIDriverDeployment()
+	g.Expect(ReconcileKubevirtCSIDriverDeployment(deployment, platform, "kubevirt-csi-storage-class-enforcement")).To(Succeed())
+
+	container := findCSIDriverContainer(deployment)
+	g.Expect(container.Args).To(ContainElement("--storage-class-enforcement-config=" + kubevirtCSIStorageClassEnforcementMountPath + "/" + kubevirtCSIStorageClassEnforcementConfigMapKey))
+
+	var volume *corev1.Volume
+	for i := range deployment.Spec.Template.Spec.Volumes {
+		if deployment.Spec.Template.Spec.Volumes[i].Name == kubevirtCSIStorageClassEnforcementVolumeName {
+			volume = &deployment.Spec.Template.Spec.Volumes[i]
+		}
+	}
+	g.Expect(volume).ToNot(BeNil())
+	g.Expect(volume.ConfigMap.Name).To(Equal("kubevirt-csi-storage-class-enforcement"))
+
+	var mount *corev1.VolumeMount
+	for i := range container.VolumeMounts {
+		if container.VolumeMounts[i].Name == kubevirtCSIStorageClassEnforcementVolumeName {
+			mount = &container.VolumeMounts[i]
+		}
+	}
+	g.Expect(mount).ToNot(BeNil())
+	g.Expect(mount.MountPath).To(Equal(kubevirtCSIStorageClassEnforcementMountPath))
+
+	// Reconciling again must update the volume/mount in place rather than appending duplicates.
+	g.Expect(ReconcileKubevirtCSIDriverDeployment(deployment, platform, "kubevirt-csi-storage-class-enforcement")).To(Succeed())
+	g.Expect(deployment.Spec.Template.Spec.Volumes).To(HaveLen(1))
+	g.Expect(container.VolumeMounts).To(HaveLen(1))
+}
+
+func TestReconcileKubevirtCSIStorageClassEnforcementConfigMap(t *testing.T) {
+	g := NewWithT(t)
+
+	platform := &v1beta1.KubevirtPlatformSpec{
+		InfraStorageClassEnforcement: &v1beta1.InfraStorageClassEnforcement{
+			AllowList:    []string{"fast"},
+			AllowDefault: true,
+		},
+	}
+
+	cm := &corev1.ConfigMap{}
+	g.Expect(ReconcileKubevirtCSIStorageClassEnforcementConfigMap(cm, platform)).To(Succeed())
+
+	g.Expect(cm.Data).To(HaveKey(kubevirtCSIStorageClassEnforcementConfigMapKey))
+	g.Expect(cm.Data[kubevirtCSIStorageClassEnforcementConfigMapKey]).To(ContainSubstring("fast"))
+}
@@ -0,0 +1,283 @@
+// Package hostedcluster contains the HostedCluster admission webhook, which validates platform
+// specific invariants that can't be expressed as CRD schema constraints.
+package hostedcluster
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/openshift/hypershift/api/v1beta1"
+	"github.com/openshift/hypershift/kubevirtexternalinfra"
+)
+
+const (
+	// minSupportedCNVVersion is the oldest CNV version the KubeVirt platform supports on the
+	// external infra cluster, independent of which optional features are in use.
+	minSupportedCNVVersion = "1.0.0"
+	// minSupportedK8sVersion is the oldest Kubernetes version the KubeVirt platform supports on
+	// the external infra cluster, independent of which optional features are in use.
+	minSupportedK8sVersion = "1.27.0"
+
+	// featureCore is always required, regardless of which optional KubeVirt features a
+	// HostedCluster and its NodePools enable.
+	featureCore = "Core"
+
+	// FeatureQoSClassGuaranteed is requested by a NodePool whose Compute.QosClass is Guaranteed.
+	FeatureQoSClassGuaranteed = "QoSClassGuaranteed"
+	// FeatureNetworkInterfaceMultiQueue is requested by a NodePool with NetworkInterfaceMultiQueue
+	// enabled.
+	FeatureNetworkInterfaceMultiQueue = "NetworkInterfaceMultiQueue"
+	// FeatureCacheStrategyPVC is requested by a NodePool whose root volume cache strategy is PVC.
+	FeatureCacheStrategyPVC = "CacheStrategyPVC"
+	// FeatureHotplugVolume is requested by a NodePool with a hotpluggable root volume.
+	FeatureHotplugVolume = "HotplugVolume"
+)
+
+// VersionRequirement pins the minimum infra cluster component versions a KubeVirt feature needs.
+// An empty field means that component imposes no additional requirement beyond the core minimum.
+type VersionRequirement struct {
+	MinCNVVersion string
+	MinK8sVersion string
+	MinCDIVersion string
+}
+
+// KubevirtFeatureRequirements maps a KubeVirt feature key to the infra cluster component versions
+// it requires. It's keyed by the same feature keys collectEnabledFeatures derives from the
+// HostedCluster and NodePool platform specs, so a new feature's compatibility requirement can be
+// added here without touching the validator.
+var KubevirtFeatureRequirements = map[string]VersionRequirement{
+	featureCore:                       {MinCNVVersion: minSupportedCNVVersion, MinK8sVersion: minSupportedK8sVersion},
+	FeatureQoSClassGuaranteed:         {MinCNVVersion: "1.1.0"},
+	FeatureNetworkInterfaceMultiQueue: {MinCNVVersion: "1.2.0", MinK8sVersion: "1.28.0"},
+	FeatureCacheStrategyPVC:           {MinCNVVersion: "1.1.0"},
+	FeatureHotplugVolume:              {MinCDIVersion: "1.57.0"},
+}
+
+// kubevirtClusterValidator validates the KubeVirt-specific fields of a HostedCluster, including
+// invariants that depend on the state of the external infra cluster it's scheduled onto.
+type kubevirtClusterValidator struct {
+	clientMap kubevirtexternalinfra.KubevirtInfraClientMap
+}
+
+// validate rejects a KubeVirt HostedCluster whose spec, or the external infra cluster it depends
+// on, can't safely support it. HostedClusters on other platforms are always accepted.
+func (v kubevirtClusterValidator) validate(ctx context.Context, cl client.Client, hc *v1beta1.HostedCluster) error {
+	if hc.Spec.Platform.Type != v1beta1.KubevirtPlatform {
+		return nil
+	}
+
+	platform := hc.Spec.Platform.Kubevirt
+	if platform == nil {
+		return fmt.Errorf("the kubevirt platform spec must not be nil")
+	}
+
+	var errs []error
+
+	cnvVersion, k8sVersion, cdiVersion, err := v.clientMap.Versions(ctx, cl, hc)
+	if err != nil {
+		return fmt.Errorf("failed to discover infra cluster versions: %w", err)
+	}
+
+	nodePools, err := v.listNodePools(ctx, cl, hc)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	for feature := range collectEnabledFeatures(platform, nodePools) {
+		req, ok := KubevirtFeatureRequirements[feature]
+		if !ok {
+			continue
+		}
+		if req.MinCNVVersion != "" && !versionAtLeast(cnvVersion, req.MinCNVVersion) {
+			errs = append(errs, fmt.Errorf("feature %q requires infra cluster CNV version >= %q, got %q", feature, req.MinCNVVersion, cnvVersion))
+		}
+		if req.MinK8sVersion != "" && !versionAtLeast(k8sVersion, req.MinK8sVersion) {
+			errs = append(errs, fmt.Errorf("feature %q requires infra cluster Kubernetes version >= %q, got %q", feature, req.MinK8sVersion, k8sVersion))
+		}
+		if req.MinCDIVersion != "" && !versionAtLeast(cdiVersion, req.MinCDIVersion) {
+			errs = append(errs, fmt.Errorf("feature %q requires infra cluster CDI version >= %q, got %q", feature, req.MinCDIVersion, cdiVersion))
+		}
+	}
+
+	if err := v.validateInfraClusterLabels(ctx, cl, hc, platform); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := v.validateInfraStorageClassEnforcement(ctx, cl, hc, platform); err != nil {
+		errs = append(errs, err)
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// validateInfraClusterLabels rejects an empty InfraClusterLabels when another KubeVirt
+// HostedCluster already shares this one's infra namespace: without labels scoping each tenant's
+// PVCs, the kubevirt-csi-driver on the shared infra cluster can't tell them apart (GHSA-fg9q-5cw2-p6r9).
+func (v kubevirtClusterValidator) validateInfraClusterLabels(ctx context.Context, cl client.Client, hc *v1beta1.HostedCluster, platform *v1beta1.KubevirtPlatformSpec) error {
+	infraNamespace := kubevirtInfraNamespace(hc, platform)
+
+	hcList := &v1beta1.HostedClusterList{}
+	if err := cl.List(ctx, hcList); err != nil {
+		return fmt.Errorf("failed to list HostedClusters: %w", err)
+	}
+
+	sharing := 0
+	for i := range hcList.Items {
+		other := &hcList.Items[i]
+		if other.Spec.Platform.Type != v1beta1.KubevirtPlatform || other.Spec.Platform.Kubevirt == nil {
+			continue
+		}
+		if other.Namespace == hc.Namespace && other.Name == hc.Name {
+			continue
+		}
+		if kubevirtInfraNamespace(other, other.Spec.Platform.Kubevirt) == infraNamespace {
+			sharing++
+		}
+	}
+
+	if sharing > 0 && len(platform.InfraClusterLabels) == 0 {
+		return fmt.Errorf("InfraClusterLabels must be set: %d other KubeVirt HostedCluster(s) share infra namespace %q", sharing, infraNamespace)
+	}
+
+	return nil
+}
+
+// validateInfraStorageClassEnforcement rejects an InfraStorageClassMappings entry that either
+// isn't permitted by InfraStorageClassEnforcement or doesn't name a storage class that actually
+// exists on the infra cluster. A nil enforcement policy, or one with AllowAll set, allows any
+// mapping.
+func (v kubevirtClusterValidator) validateInfraStorageClassEnforcement(ctx context.Context, cl client.Client, hc *v1beta1.HostedCluster, platform *v1beta1.KubevirtPlatformSpec) error {
+	enforcement := platform.InfraStorageClassEnforcement
+	if enforcement == nil || enforcement.AllowAll {
+		return nil
+	}
+
+	allowed := make(map[string]bool, len(enforcement.AllowList))
+	for _, name := range enforcement.AllowList {
+		allowed[name] = true
+	}
+
+	existingClasses, err := v.clientMap.StorageClasses(ctx, cl, hc)
+	if err != nil {
+		return fmt.Errorf("failed to list infra cluster storage classes: %w", err)
+	}
+	existing := make(map[string]bool, len(existingClasses))
+	for _, name := range existingClasses {
+		existing[name] = true
+	}
+
+	var errs []error
+	for _, mapping := range platform.InfraStorageClassMappings {
+		if mapping == "" {
+			if !enforcement.AllowDefault {
+				errs = append(errs, fmt.Errorf("the infra cluster's default storage class is not permitted by InfraStorageClassEnforcement"))
+			}
+			continue
+		}
+
+		// Each mapping is an "infraStorageClass/guestStorageClass" pair; enforcement and
+		// existence are both checked against the infra side, since that's the class the
+		// kubevirt-csi-driver actually provisions against on the shared infra cluster.
+		infraClass, _, found := strings.Cut(mapping, "/")
+		if !found {
+			errs = append(errs, fmt.Errorf("storage class mapping %q must be in the form \"infraStorageClass/guestStorageClass\"", mapping))
+			continue
+		}
+
+		if !allowed[infraClass] {
+			errs = append(errs, fmt.Errorf("storage class %q is not permitted by InfraStorageClassEnforcement", infraClass))
+		}
+		if !existing[infraClass] {
+			errs = append(errs, fmt.Errorf("storage class %q does not exist on the infra cluster", infraClass))
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// listNodePools returns the NodePools belonging to hc.
+func (v kubevirtClusterValidator) listNodePools(ctx context.Context, cl client.Client, hc *v1beta1.HostedCluster) ([]v1beta1.NodePool, error) {
+	npList := &v1beta1.NodePoolList{}
+	if err := cl.List(ctx, npList, client.InNamespace(hc.Namespace)); err != nil {
+		return nil, fmt.Errorf("failed to list NodePools: %w", err)
+	}
+
+	var owned []v1beta1.NodePool
+	for _, np := range npList.Items {
+		if np.Spec.ClusterName == hc.Name {
+			owned = append(owned, np)
+		}
+	}
+	return owned, nil
+}
+
+// collectEnabledFeatures returns the set of KubevirtFeatureRequirements keys that the HostedCluster
+// and its NodePools request, including the always-on featureCore.
+func collectEnabledFeatures(platform *v1beta1.KubevirtPlatformSpec, nodePools []v1beta1.NodePool) map[string]bool {
+	features := map[string]bool{featureCore: true}
+
+	for _, np := range nodePools {
+		kv := np.Spec.Platform.Kubevirt
+		if kv == nil {
+			continue
+		}
+		if kv.Compute != nil && kv.Compute.QosClass != nil && *kv.Compute.QosClass == v1beta1.QoSClassGuaranteed {
+			features[FeatureQoSClassGuaranteed] = true
+		}
+		if kv.NetworkInterfaceMultiQueue != nil && *kv.NetworkInterfaceMultiQueue == v1beta1.MultiQueueEnable {
+			features[FeatureNetworkInterfaceMultiQueue] = true
+		}
+		if kv.RootVolume != nil {
+			if kv.RootVolume.CacheStrategy != nil && kv.RootVolume.CacheStrategy.Type == v1beta1.KubevirtCachingStrategyPVC {
+				features[FeatureCacheStrategyPVC] = true
+			}
+			if kv.RootVolume.Hotplug {
+				features[FeatureHotplugVolume] = true
+			}
+		}
+	}
+
+	return features
+}
+
+// kubevirtInfraNamespace returns the namespace on the external infra cluster that this
+// HostedCluster's tenant resources are provisioned into: the configured external infra
+// namespace, or the HostedCluster's own namespace when it manages its own infra cluster.
+func kubevirtInfraNamespace(hc *v1beta1.HostedCluster, platform *v1beta1.KubevirtPlatformSpec) string {
+	if platform.Credentials != nil && platform.Credentials.InfraNamespace != "" {
+		return platform.Credentials.InfraNamespace
+	}
+	return hc.Namespace
+}
+
+// versionAtLeast reports whether version is greater than or equal to min, comparing dotted
+// numeric version strings component by component.
+func versionAtLeast(version, min string) bool {
+	v := parseVersion(version)
+	m := parseVersion(min)
+	for i := 0; i < len(m); i++ {
+		var vPart int
+		if i < len(v) {
+			vPart = v[i]
+		}
+		if vPart != m[i] {
+			return vPart > m[i]
+		}
+	}
+	return true
+}
+
+func parseVersion(version string) []int {
+	fields := strings.Split(version, ".")
+	parts := make([]int, len(fields))
+	for i, f := range fields {
+		n, _ := strconv.Atoi(f)
+		parts[i] = n
+	}
+	return parts
+}
@@ -6,19 +6,28 @@ import (
 	"testing"
 
 	"github.com/openshift/hypershift/api/v1beta1"
+	storagev1 "k8s.io/api/storage/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	"github.com/openshift/hypershift/support/api"
 )
 
 func TestValidateKubevirtCluster(t *testing.T) {
+	qosClassGuaranteed := v1beta1.QoSClassGuaranteed
+	multiQueueEnable := v1beta1.MultiQueueEnable
+
 	for _, testCase := range []struct {
-		name        string
-		hc          *v1beta1.HostedCluster
-		cnvVersion  string
-		k8sVersion  string
-		expectError bool
+		name                   string
+		hc                     *v1beta1.HostedCluster
+		existingHostedClusters []v1beta1.HostedCluster
+		existingStorageClasses []string
+		existingNodePools      []v1beta1.NodePool
+		cnvVersion             string
+		k8sVersion             string
+		cdiVersion             string
+		expectError            bool
 	}{
 		{
 			name: "happy case - versions are valid",
@@ -91,10 +100,368 @@ func TestValidateKubevirtCluster(t *testing.T) {
 			k8sVersion:  "1.27.0",
 			expectError: true,
 		},
+		{
+			name: "shared infra namespace without InfraClusterLabels is rejected",
+			hc: &v1beta1.HostedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "cluster-under-test",
+					Namespace: "myns",
+				},
+				Spec: v1beta1.HostedClusterSpec{
+					Platform: v1beta1.PlatformSpec{
+						Type: v1beta1.KubevirtPlatform,
+						Kubevirt: &v1beta1.KubevirtPlatformSpec{
+							Credentials: &v1beta1.KubevirtPlatformCredentials{InfraNamespace: "shared-infra"},
+						},
+					},
+				},
+			},
+			existingHostedClusters: []v1beta1.HostedCluster{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "other-cluster", Namespace: "other-ns"},
+					Spec: v1beta1.HostedClusterSpec{
+						Platform: v1beta1.PlatformSpec{
+							Type: v1beta1.KubevirtPlatform,
+							Kubevirt: &v1beta1.KubevirtPlatformSpec{
+								Credentials: &v1beta1.KubevirtPlatformCredentials{InfraNamespace: "shared-infra"},
+							},
+						},
+					},
+				},
+			},
+			cnvVersion:  "1.0.0",
+			k8sVersion:  "1.27.0",
+			expectError: true,
+		},
+		{
+			name: "shared infra namespace with InfraClusterLabels set on both tenants is accepted",
+			hc: &v1beta1.HostedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "cluster-under-test",
+					Namespace: "myns",
+				},
+				Spec: v1beta1.HostedClusterSpec{
+					Platform: v1beta1.PlatformSpec{
+						Type: v1beta1.KubevirtPlatform,
+						Kubevirt: &v1beta1.KubevirtPlatformSpec{
+							Credentials:        &v1beta1.KubevirtPlatformCredentials{InfraNamespace: "shared-infra"},
+							InfraClusterLabels: map[string]string{"tenant": "cluster-under-test"},
+						},
+					},
+				},
+			},
+			existingHostedClusters: []v1beta1.HostedCluster{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "other-cluster", Namespace: "other-ns"},
+					Spec: v1beta1.HostedClusterSpec{
+						Platform: v1beta1.PlatformSpec{
+							Type: v1beta1.KubevirtPlatform,
+							Kubevirt: &v1beta1.KubevirtPlatformSpec{
+								Credentials:        &v1beta1.KubevirtPlatformCredentials{InfraNamespace: "shared-infra"},
+								InfraClusterLabels: map[string]string{"tenant": "other-cluster"},
+							},
+						},
+					},
+				},
+			},
+			cnvVersion:  "1.0.0",
+			k8sVersion:  "1.27.0",
+			expectError: false,
+		},
+		{
+			name: "unrelated HostedCluster in a different infra namespace does not require InfraClusterLabels",
+			hc: &v1beta1.HostedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "cluster-under-test",
+					Namespace: "myns",
+				},
+				Spec: v1beta1.HostedClusterSpec{
+					Platform: v1beta1.PlatformSpec{
+						Type:     v1beta1.KubevirtPlatform,
+						Kubevirt: &v1beta1.KubevirtPlatformSpec{},
+					},
+				},
+			},
+			existingHostedClusters: []v1beta1.HostedCluster{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "other-cluster", Namespace: "other-ns"},
+					Spec: v1beta1.HostedClusterSpec{
+						Platform: v1beta1.PlatformSpec{
+							Type: v1beta1.KubevirtPlatform,
+							Kubevirt: &v1beta1.KubevirtPlatformSpec{
+								Credentials: &v1beta1.KubevirtPlatformCredentials{InfraNamespace: "some-other-infra"},
+							},
+						},
+					},
+				},
+			},
+			cnvVersion:  "1.0.0",
+			k8sVersion:  "1.27.0",
+			expectError: false,
+		},
+		{
+			name: "storage class enforcement with empty allow list rejects a mapped storage class",
+			hc: &v1beta1.HostedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "cluster-under-test",
+					Namespace: "myns",
+				},
+				Spec: v1beta1.HostedClusterSpec{
+					Platform: v1beta1.PlatformSpec{
+						Type: v1beta1.KubevirtPlatform,
+						Kubevirt: &v1beta1.KubevirtPlatformSpec{
+							InfraStorageClassEnforcement: &v1beta1.InfraStorageClassEnforcement{},
+							InfraStorageClassMappings:    []string{"ceph-rbd/ocs-sc"},
+						},
+					},
+				},
+			},
+			existingStorageClasses: []string{"ceph-rbd"},
+			cnvVersion:             "1.0.0",
+			k8sVersion:             "1.27.0",
+			expectError:            true,
+		},
+		{
+			name: "storage class enforcement rejects a mapping that does not exist on the infra cluster",
+			hc: &v1beta1.HostedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "cluster-under-test",
+					Namespace: "myns",
+				},
+				Spec: v1beta1.HostedClusterSpec{
+					Platform: v1beta1.PlatformSpec{
+						Type: v1beta1.KubevirtPlatform,
+						Kubevirt: &v1beta1.KubevirtPlatformSpec{
+							InfraStorageClassEnforcement: &v1beta1.InfraStorageClassEnforcement{
+								AllowList: []string{"ceph-rbd"},
+							},
+							InfraStorageClassMappings: []string{"ceph-rbd/ocs-sc"},
+						},
+					},
+				},
+			},
+			existingStorageClasses: []string{},
+			cnvVersion:             "1.0.0",
+			k8sVersion:             "1.27.0",
+			expectError:            true,
+		},
+		{
+			name: "storage class enforcement accepts a mapping that is allowed and exists",
+			hc: &v1beta1.HostedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "cluster-under-test",
+					Namespace: "myns",
+				},
+				Spec: v1beta1.HostedClusterSpec{
+					Platform: v1beta1.PlatformSpec{
+						Type: v1beta1.KubevirtPlatform,
+						Kubevirt: &v1beta1.KubevirtPlatformSpec{
+							InfraStorageClassEnforcement: &v1beta1.InfraStorageClassEnforcement{
+								AllowList: []string{"ceph-rbd"},
+							},
+							InfraStorageClassMappings: []string{"ceph-rbd/ocs-sc"},
+						},
+					},
+				},
+			},
+			existingStorageClasses: []string{"ceph-rbd"},
+			cnvVersion:             "1.0.0",
+			k8sVersion:             "1.27.0",
+			expectError:            false,
+		},
+		{
+			name: "storage class enforcement accepts an infra/guest mapping validated against the infra side",
+			hc: &v1beta1.HostedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "cluster-under-test",
+					Namespace: "myns",
+				},
+				Spec: v1beta1.HostedClusterSpec{
+					Platform: v1beta1.PlatformSpec{
+						Type: v1beta1.KubevirtPlatform,
+						Kubevirt: &v1beta1.KubevirtPlatformSpec{
+							InfraStorageClassEnforcement: &v1beta1.InfraStorageClassEnforcement{
+								AllowList: []string{"ceph-rbd"},
+							},
+							InfraStorageClassMappings: []string{"ceph-rbd/ocs-sc"},
+						},
+					},
+				},
+			},
+			existingStorageClasses: []string{"ceph-rbd"},
+			cnvVersion:             "1.0.0",
+			k8sVersion:             "1.27.0",
+			expectError:            false,
+		},
+		{
+			name: "storage class enforcement rejects a mapping missing the infra/guest separator",
+			hc: &v1beta1.HostedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "cluster-under-test",
+					Namespace: "myns",
+				},
+				Spec: v1beta1.HostedClusterSpec{
+					Platform: v1beta1.PlatformSpec{
+						Type: v1beta1.KubevirtPlatform,
+						Kubevirt: &v1beta1.KubevirtPlatformSpec{
+							InfraStorageClassEnforcement: &v1beta1.InfraStorageClassEnforcement{
+								AllowList: []string{"ceph-rbd"},
+							},
+							InfraStorageClassMappings: []string{"ceph-rbd-ocs-sc"},
+						},
+					},
+				},
+			},
+			existingStorageClasses: []string{"ceph-rbd"},
+			cnvVersion:             "1.0.0",
+			k8sVersion:             "1.27.0",
+			expectError:            true,
+		},
+		{
+			name: "QoSClassGuaranteed feature rejects CNV version below its minimum",
+			hc: &v1beta1.HostedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "cluster-under-test",
+					Namespace: "myns",
+				},
+				Spec: v1beta1.HostedClusterSpec{
+					Platform: v1beta1.PlatformSpec{
+						Type:     v1beta1.KubevirtPlatform,
+						Kubevirt: &v1beta1.KubevirtPlatformSpec{},
+					},
+				},
+			},
+			existingNodePools: []v1beta1.NodePool{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "np", Namespace: "myns"},
+					Spec: v1beta1.NodePoolSpec{
+						ClusterName: "cluster-under-test",
+						Platform: v1beta1.NodePoolPlatform{
+							Type: v1beta1.KubevirtPlatform,
+							Kubevirt: &v1beta1.KubevirtNodePoolPlatform{
+								Compute: &v1beta1.KubevirtCompute{QosClass: &qosClassGuaranteed},
+							},
+						},
+					},
+				},
+			},
+			cnvVersion:  "1.0.99",
+			k8sVersion:  "1.27.0",
+			expectError: true,
+		},
+		{
+			name: "NetworkInterfaceMultiQueue feature rejects CNV and K8s versions below its minimum",
+			hc: &v1beta1.HostedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "cluster-under-test",
+					Namespace: "myns",
+				},
+				Spec: v1beta1.HostedClusterSpec{
+					Platform: v1beta1.PlatformSpec{
+						Type:     v1beta1.KubevirtPlatform,
+						Kubevirt: &v1beta1.KubevirtPlatformSpec{},
+					},
+				},
+			},
+			existingNodePools: []v1beta1.NodePool{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "np", Namespace: "myns"},
+					Spec: v1beta1.NodePoolSpec{
+						ClusterName: "cluster-under-test",
+						Platform: v1beta1.NodePoolPlatform{
+							Type: v1beta1.KubevirtPlatform,
+							Kubevirt: &v1beta1.KubevirtNodePoolPlatform{
+								NetworkInterfaceMultiQueue: &multiQueueEnable,
+							},
+						},
+					},
+				},
+			},
+			cnvVersion:  "1.1.99",
+			k8sVersion:  "1.27.99",
+			expectError: true,
+		},
+		{
+			name: "CacheStrategyPVC feature rejects CNV version below its minimum",
+			hc: &v1beta1.HostedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "cluster-under-test",
+					Namespace: "myns",
+				},
+				Spec: v1beta1.HostedClusterSpec{
+					Platform: v1beta1.PlatformSpec{
+						Type:     v1beta1.KubevirtPlatform,
+						Kubevirt: &v1beta1.KubevirtPlatformSpec{},
+					},
+				},
+			},
+			existingNodePools: []v1beta1.NodePool{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "np", Namespace: "myns"},
+					Spec: v1beta1.NodePoolSpec{
+						ClusterName: "cluster-under-test",
+						Platform: v1beta1.NodePoolPlatform{
+							Type: v1beta1.KubevirtPlatform,
+							Kubevirt: &v1beta1.KubevirtNodePoolPlatform{
+								RootVolume: &v1beta1.KubevirtRootVolume{
+									CacheStrategy: &v1beta1.KubevirtCachingStrategy{Type: v1beta1.KubevirtCachingStrategyPVC},
+								},
+							},
+						},
+					},
+				},
+			},
+			cnvVersion:  "1.0.99",
+			k8sVersion:  "1.27.0",
+			expectError: true,
+		},
+		{
+			name: "HotplugVolume feature rejects CDI version below its minimum",
+			hc: &v1beta1.HostedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "cluster-under-test",
+					Namespace: "myns",
+				},
+				Spec: v1beta1.HostedClusterSpec{
+					Platform: v1beta1.PlatformSpec{
+						Type:     v1beta1.KubevirtPlatform,
+						Kubevirt: &v1beta1.KubevirtPlatformSpec{},
+					},
+				},
+			},
+			existingNodePools: []v1beta1.NodePool{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "np", Namespace: "myns"},
+					Spec: v1beta1.NodePoolSpec{
+						ClusterName: "cluster-under-test",
+						Platform: v1beta1.NodePoolPlatform{
+							Type: v1beta1.KubevirtPlatform,
+							Kubevirt: &v1beta1.KubevirtNodePoolPlatform{
+								RootVolume: &v1beta1.KubevirtRootVolume{Hotplug: true},
+							},
+						},
+					},
+				},
+			},
+			cnvVersion:  "1.0.0",
+			k8sVersion:  "1.27.0",
+			cdiVersion:  "1.56.0",
+			expectError: true,
+		},
 	} {
 		t.Run(testCase.name, func(tt *testing.T) {
-			cl := fake.NewClientBuilder().WithScheme(api.Scheme).Build()
-			clientMap := kubevirtexternalinfra.NewMockKubevirtInfraClientMap(cl, testCase.cnvVersion, testCase.k8sVersion)
+			objs := make([]client.Object, 0, len(testCase.existingHostedClusters)+len(testCase.existingStorageClasses)+len(testCase.existingNodePools))
+			for i := range testCase.existingHostedClusters {
+				objs = append(objs, &testCase.existingHostedClusters[i])
+			}
+			for _, name := range testCase.existingStorageClasses {
+				objs = append(objs, &storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: name}})
+			}
+			for i := range testCase.existingNodePools {
+				objs = append(objs, &testCase.existingNodePools[i])
+			}
+			cl := fake.NewClientBuilder().WithScheme(api.Scheme).WithObjects(objs...).Build()
+			clientMap := kubevirtexternalinfra.NewMockKubevirtInfraClientMap(cl, testCase.cnvVersion, testCase.k8sVersion, testCase.cdiVersion)
 
 			v := kubevirtClusterValidator{
 				clientMap: clientMap,
@@ -0,0 +1,817 @@
+// Package kubevirt builds the CAPI KubevirtMachineTemplate for KubeVirt-platform
+// NodePools and manages the lifecycle of the boot-image DataVolume they reference.
+package kubevirt
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	suppconfig "github.com/openshift/hypershift/support/config"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apiresource "k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+	"kubevirt.io/containerized-data-importer-api/pkg/apis/core/v1beta1"
+	capikubevirt "sigs.k8s.io/cluster-api-provider-kubevirt/api/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	hyperv1 "github.com/openshift/hypershift/api/v1beta1"
+)
+
+// SpecHashAnnotation is stamped on both the generated KubevirtMachineTemplate and the resulting
+// VirtualMachine so a drift-detection controller can tell whether a running VM still matches the
+// NodePool's desired KubeVirt platform spec.
+const SpecHashAnnotation = "hypershift.openshift.io/kubevirt-spec-hash"
+
+const (
+	bootImageNamePrefix       = "boot-image-"
+	bootImageDVLabelRoleName  = "hypershift.openshift.io/boot-image"
+	bootImageDVLabelRoleValue = "true"
+	bootImageDVLabelUID       = "hypershift.openshift.io/infra-id"
+	bootImageDVAnnotationHash = "hypershift.openshift.io/boot-image-hash"
+
+	rootVolumeName     = "rhcos"
+	defaultNetworkName = "default"
+	rootVolumeDiskBus  = "virtio"
+
+	dataImportCronNamePrefix = "boot-image-cron-"
+	defaultImportsToKeep     = int32(2)
+)
+
+// LocalStorageVolumes lists the volume names that are backed by local/ephemeral
+// storage on the infra cluster node; it is surfaced as a pod annotation so the
+// eviction controller knows the VM's pod can't simply be rescheduled elsewhere.
+var LocalStorageVolumes = []string{rootVolumeName}
+
+// cachedBootImage tracks the DataVolume that backs the boot disk for every
+// VirtualMachine generated for a given NodePool.
+type cachedBootImage struct {
+	image      string
+	hash       string
+	namespace  string
+	autoUpdate bool
+
+	// tenantLabels are stamped on every DataVolume/DataImportCron this cachedBootImage creates,
+	// in addition to the built-in role/infra-id labels, and are required (all of them) for an
+	// existing DataVolume to be recognized as belonging to this tenant. They are exactly the
+	// extraLabels passed to newCachedBootImage; no hosted-cluster UID label is added here, so
+	// one is only required if the caller includes it in extraLabels.
+	tenantLabels map[string]string
+
+	// dvName is the name of the pinned-mode boot DataVolume, set once CacheImage resolves it.
+	dvName string
+	// dataSourceName is the managed DataSource name backing the cron-mode DataImportCron,
+	// set once CacheImage resolves it. Mutually exclusive with dvName.
+	dataSourceName string
+}
+
+// ActiveSourceName returns the name of the volume source the generated VirtualMachines should
+// clone their boot disk from: the pinned DataVolume in the default mode, or the DataImportCron's
+// managed DataSource when auto-update tracking is enabled.
+func (c *cachedBootImage) ActiveSourceName() string {
+	if c.dataSourceName != "" {
+		return c.dataSourceName
+	}
+	return c.dvName
+}
+
+// newCachedBootImage constructs a cachedBootImage. extraLabels is the operator-provided
+// allow-list of labels (e.g. from a --kubevirt-infra-labels flag) that, together with the
+// built-in role/infra-id labels, every boot-image DataVolume this cachedBootImage manages must
+// carry for the full tenant-scoping label set requirement described on tenantLabels.
+func newCachedBootImage(image, hash, namespace string, autoUpdate bool, extraLabels map[string]string) *cachedBootImage {
+	return &cachedBootImage{
+		image:        image,
+		hash:         hash,
+		namespace:    namespace,
+		autoUpdate:   autoUpdate,
+		tenantLabels: extraLabels,
+	}
+}
+
+// requiredLabels returns the full set of labels a boot-image DataVolume/DataImportCron for this
+// cachedBootImage must carry: the built-in role and infra-id labels plus any operator-configured
+// tenant labels. A DataVolume missing even one of these is never adopted, reused, or cleaned up,
+// even if its hash annotation matches.
+func (c *cachedBootImage) requiredLabels(infraID string) map[string]string {
+	labels := map[string]string{
+		bootImageDVLabelRoleName: bootImageDVLabelRoleValue,
+		bootImageDVLabelUID:      infraID,
+	}
+	for k, v := range c.tenantLabels {
+		labels[k] = v
+	}
+	return labels
+}
+
+// CacheImage ensures the boot volume for this NodePool's release image is available in the
+// hosted cluster's infra namespace. In the default (pinned) mode it creates a single DataVolume
+// keyed on the image hash and cleans up siblings left behind by a previous image hash. When the
+// NodePool requests AutoUpdate tracking it instead reconciles a CDI DataImportCron and lets CDI
+// keep its managed DataSource pointed at the latest import, without touching any DataVolume
+// already backing a running generation of VMs. Either way, it also tears down whatever the other
+// mode left behind, so flipping AutoUpdate on or off doesn't leak the previous mode's resource.
+func (c *cachedBootImage) CacheImage(ctx context.Context, cl client.Client, nodePool *hyperv1.NodePool, infraID string) error {
+	if c.autoUpdate {
+		if err := c.reconcileDataImportCron(ctx, cl, nodePool, infraID); err != nil {
+			return err
+		}
+		return c.cleanupPinnedDataVolumes(ctx, cl, infraID)
+	}
+
+	if err := c.reconcilePinnedDataVolume(ctx, cl, infraID); err != nil {
+		return err
+	}
+	return c.cleanupDataImportCron(ctx, cl, nodePool, infraID)
+}
+
+// ownedDataVolumes returns this cachedBootImage's boot-image DataVolumes: every DataVolume in the
+// infra namespace carrying the full requiredLabels set.
+func (c *cachedBootImage) ownedDataVolumes(ctx context.Context, cl client.Client, infraID string) ([]v1beta1.DataVolume, error) {
+	labels := c.requiredLabels(infraID)
+
+	dvList := &v1beta1.DataVolumeList{}
+	if err := cl.List(ctx, dvList, client.InNamespace(c.namespace), client.MatchingLabels(labels)); err != nil {
+		return nil, fmt.Errorf("failed to list boot image DataVolumes: %w", err)
+	}
+
+	// MatchingLabels already intersects on the required label set, but re-check explicitly: a DV
+	// missing even one required label must never be adopted or cleaned up, even if its hash
+	// annotation matches.
+	owned := dvList.Items[:0]
+	for _, dv := range dvList.Items {
+		if hasLabels(dv.Labels, labels) {
+			owned = append(owned, dv)
+		}
+	}
+	return owned, nil
+}
+
+// cleanupPinnedDataVolumes deletes every pinned-mode boot-image DataVolume belonging to this
+// cachedBootImage. It's called once AutoUpdate tracking is active, at which point ActiveSourceName
+// always resolves to the DataImportCron's DataSource and no VirtualMachine clones from a pinned DV
+// anymore, so switching a NodePool from pinned to AutoUpdate mode doesn't leave the old DV behind.
+func (c *cachedBootImage) cleanupPinnedDataVolumes(ctx context.Context, cl client.Client, infraID string) error {
+	owned, err := c.ownedDataVolumes(ctx, cl, infraID)
+	if err != nil {
+		return err
+	}
+	for i := range owned {
+		dv := &owned[i]
+		if err := cl.Delete(ctx, dv); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete pinned boot image DataVolume %s/%s left over from pinned mode: %w", dv.Namespace, dv.Name, err)
+		}
+	}
+	return nil
+}
+
+// cleanupDataImportCron deletes this NodePool's boot-image DataImportCron, if one exists. It's
+// called whenever AutoUpdate tracking isn't in use, so switching a NodePool from AutoUpdate back
+// to pinned mode doesn't leave the cron (and the import traffic it generates) running forever.
+func (c *cachedBootImage) cleanupDataImportCron(ctx context.Context, cl client.Client, nodePool *hyperv1.NodePool, infraID string) error {
+	cron := &v1beta1.DataImportCron{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      dataImportCronNamePrefix + nodePool.Name,
+			Namespace: c.namespace,
+		},
+	}
+	if err := cl.Delete(ctx, cron); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete boot image DataImportCron %s/%s left over from AutoUpdate mode: %w", cron.Namespace, cron.Name, err)
+	}
+	return nil
+}
+
+func (c *cachedBootImage) reconcilePinnedDataVolume(ctx context.Context, cl client.Client, infraID string) error {
+	labels := c.requiredLabels(infraID)
+
+	owned, err := c.ownedDataVolumes(ctx, cl, infraID)
+	if err != nil {
+		return err
+	}
+
+	for _, dv := range owned {
+		if dv.Annotations[bootImageDVAnnotationHash] == c.hash {
+			c.dvName = dv.Name
+			return nil
+		}
+	}
+
+	// No DataVolume matches the current hash; clean up any stale sibling before creating
+	// a new one so we don't leak DataVolumes across image upgrades.
+	for i := range owned {
+		dv := &owned[i]
+		if err := cl.Delete(ctx, dv); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete stale boot image DataVolume %s/%s: %w", dv.Namespace, dv.Name, err)
+		}
+	}
+
+	dv := &v1beta1.DataVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: bootImageNamePrefix,
+			Namespace:    c.namespace,
+			Labels:       labels,
+			Annotations: map[string]string{
+				bootImageDVAnnotationHash: c.hash,
+			},
+		},
+		Spec: v1beta1.DataVolumeSpec{
+			Source: &v1beta1.DataVolumeSource{
+				HTTP: &v1beta1.DataVolumeSourceHTTP{
+					URL: c.image,
+				},
+			},
+		},
+	}
+
+	if err := cl.Create(ctx, dv); err != nil {
+		return fmt.Errorf("failed to create boot image DataVolume: %w", err)
+	}
+
+	c.dvName = dv.Name
+	return nil
+}
+
+// hasLabels reports whether actual carries every key/value pair in required.
+func hasLabels(actual, required map[string]string) bool {
+	for k, v := range required {
+		if actual[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// reconcileDataImportCron creates or updates the CDI DataImportCron that keeps this NodePool's
+// boot image tracking a rolling channel, and records the cron's managed DataSource name. It
+// deliberately never touches DataVolumes: those backing already-running VMs must keep cloning
+// from the generation they were created with.
+func (c *cachedBootImage) reconcileDataImportCron(ctx context.Context, cl client.Client, nodePool *hyperv1.NodePool, infraID string) error {
+	rootVolume := nodePool.Spec.Platform.Kubevirt.RootVolume
+	if rootVolume == nil || rootVolume.Image == nil || rootVolume.Image.AutoUpdate == nil {
+		return fmt.Errorf("auto-update cache requested but RootVolume.Image.AutoUpdate is nil")
+	}
+	autoUpdate := rootVolume.Image.AutoUpdate
+
+	cronName := dataImportCronNamePrefix + nodePool.Name
+	importsToKeep := defaultImportsToKeep
+	if autoUpdate.KeepLast > 0 {
+		importsToKeep = autoUpdate.KeepLast
+	}
+
+	desired := &v1beta1.DataImportCron{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cronName,
+			Namespace: c.namespace,
+			Labels:    c.requiredLabels(infraID),
+		},
+		Spec: v1beta1.DataImportCronSpec{
+			Schedule:          autoUpdate.Schedule,
+			ManagedDataSource: cronName,
+			ImportsToKeep:     &importsToKeep,
+			GarbageCollect:    dataImportCronGarbageCollectPtr(v1beta1.DataImportCronGarbageCollectOutdated),
+			Template: v1beta1.DataVolume{
+				Spec: v1beta1.DataVolumeSpec{
+					Source: &v1beta1.DataVolumeSource{
+						HTTP: &v1beta1.DataVolumeSourceHTTP{
+							URL: c.image,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	existing := &v1beta1.DataImportCron{}
+	err := cl.Get(ctx, client.ObjectKeyFromObject(desired), existing)
+	switch {
+	case apierrors.IsNotFound(err):
+		if err := cl.Create(ctx, desired); err != nil {
+			return fmt.Errorf("failed to create boot image DataImportCron: %w", err)
+		}
+	case err != nil:
+		return fmt.Errorf("failed to get boot image DataImportCron: %w", err)
+	default:
+		existing.Spec = desired.Spec
+		if err := cl.Update(ctx, existing); err != nil {
+			return fmt.Errorf("failed to update boot image DataImportCron: %w", err)
+		}
+	}
+
+	c.dataSourceName = cronName
+	return nil
+}
+
+func dataImportCronGarbageCollectPtr(policy v1beta1.DataImportCronGarbageCollect) *v1beta1.DataImportCronGarbageCollect {
+	return &policy
+}
+
+// MachineTemplateSpec builds the CAPI KubevirtMachineTemplateSpec for the given NodePool.
+func MachineTemplateSpec(nodePool *hyperv1.NodePool, bootImage *cachedBootImage, hcluster *hyperv1.HostedCluster) *capikubevirt.KubevirtMachineTemplateSpec {
+	return &capikubevirt.KubevirtMachineTemplateSpec{
+		Template: capikubevirt.KubevirtMachineTemplateResource{
+			Spec: capikubevirt.KubevirtMachineSpec{
+				VirtualMachineTemplate: *generateVMTemplate(nodePool, bootImage, hcluster),
+			},
+		},
+	}
+}
+
+func generateVMTemplate(nodePool *hyperv1.NodePool, bootImage *cachedBootImage, hcluster *hyperv1.HostedCluster) *capikubevirt.VirtualMachineTemplateSpec {
+	platform := nodePool.Spec.Platform.Kubevirt
+	runAlways := kubevirtv1.RunStrategyAlways
+
+	labels := map[string]string{
+		hyperv1.NodePoolNameLabel: nodePool.Name,
+		hyperv1.InfraIDLabel:      hcluster.Spec.InfraID,
+	}
+
+	template := &capikubevirt.VirtualMachineTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: labels,
+			// kubevirtdrift.Reconciler reads the spec hash off the VirtualMachine's own
+			// metadata, not the VMI template nested under Spec.Template, so it must be stamped
+			// here too or every VM looks permanently drifted.
+			Annotations: map[string]string{
+				SpecHashAnnotation: SpecHash(platform),
+			},
+		},
+		Spec: kubevirtv1.VirtualMachineSpec{
+			RunStrategy: &runAlways,
+			DataVolumeTemplates: []kubevirtv1.DataVolumeTemplateSpec{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: rootVolumeName,
+						// Stamped so the infra cluster's admission can enforce the same
+						// tenant-scoping labels on the per-VM clone as on the cached source.
+						Labels: bootImage.requiredLabels(hcluster.Spec.InfraID),
+					},
+					Spec: bootVolumeDataVolumeSpec(bootImage),
+				},
+			},
+			Template: &kubevirtv1.VirtualMachineInstanceTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+					Annotations: map[string]string{
+						suppconfig.PodSafeToEvictLocalVolumesKey:              strings.Join(LocalStorageVolumes, ","),
+						"kubevirt.io/allow-pod-bridge-network-live-migration": "",
+						SpecHashAnnotation:                                    SpecHash(platform),
+					},
+				},
+				Spec: kubevirtv1.VirtualMachineInstanceSpec{
+					Affinity: &corev1.Affinity{
+						PodAntiAffinity: &corev1.PodAntiAffinity{
+							PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{
+								{
+									Weight: int32(100),
+									PodAffinityTerm: corev1.PodAffinityTerm{
+										LabelSelector: &metav1.LabelSelector{
+											MatchExpressions: []metav1.LabelSelectorRequirement{
+												{
+													Key:      hyperv1.NodePoolNameLabel,
+													Operator: metav1.LabelSelectorOpIn,
+													Values:   []string{nodePool.Name},
+												},
+											},
+										},
+										TopologyKey: "kubernetes.io/hostname",
+									},
+								},
+							},
+						},
+					},
+					Domain: kubevirtv1.DomainSpec{
+						Devices: kubevirtv1.Devices{
+							Disks:      buildDisks(platform.PerformanceProfile),
+							Interfaces: buildInterfaces(platform),
+						},
+					},
+					Networks: buildNetworks(platform),
+					Volumes: []kubevirtv1.Volume{
+						{
+							Name: rootVolumeName,
+							VolumeSource: kubevirtv1.VolumeSource{
+								DataVolume: &kubevirtv1.DataVolumeSource{
+									Name: rootVolumeName,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if platform.Compute != nil {
+		var qosClass hyperv1.QoSClass
+		if platform.Compute.QosClass != nil {
+			qosClass = *platform.Compute.QosClass
+		}
+
+		switch qosClass {
+		case hyperv1.QoSClassGuaranteed:
+			// Sizing is conveyed entirely through Resources.Requests/Limits below; setting
+			// CPU.Cores/Memory.Guest as well would give KubeVirt two conflicting sizing sources.
+			requests := corev1.ResourceList{}
+			if platform.Compute.Memory != nil {
+				requests[corev1.ResourceMemory] = *platform.Compute.Memory
+			}
+			if platform.Compute.Cores != nil {
+				requests[corev1.ResourceCPU] = *apiresource.NewQuantity(int64(*platform.Compute.Cores), apiresource.DecimalSI)
+			}
+			template.Spec.Template.Spec.Domain.Resources.Requests = requests
+			template.Spec.Template.Spec.Domain.Resources.Limits = requests.DeepCopy()
+		case hyperv1.QoSClassBurstable:
+			requests := corev1.ResourceList{}
+			limits := corev1.ResourceList{}
+			if platform.Compute.MemoryRequest != nil {
+				requests[corev1.ResourceMemory] = *platform.Compute.MemoryRequest
+			}
+			if platform.Compute.MemoryLimit != nil {
+				limits[corev1.ResourceMemory] = *platform.Compute.MemoryLimit
+			}
+			if platform.Compute.CPURequest != nil {
+				requests[corev1.ResourceCPU] = *apiresource.NewQuantity(int64(*platform.Compute.CPURequest), apiresource.DecimalSI)
+			}
+			if platform.Compute.CPULimit != nil {
+				limits[corev1.ResourceCPU] = *apiresource.NewQuantity(int64(*platform.Compute.CPULimit), apiresource.DecimalSI)
+			}
+			template.Spec.Template.Spec.Domain.Resources.Requests = requests
+			template.Spec.Template.Spec.Domain.Resources.Limits = limits
+			if platform.Compute.Cores != nil {
+				template.Spec.Template.Spec.Domain.CPU = &kubevirtv1.CPU{Cores: *platform.Compute.Cores}
+			}
+		default:
+			if platform.Compute.Cores != nil {
+				template.Spec.Template.Spec.Domain.CPU = &kubevirtv1.CPU{Cores: *platform.Compute.Cores}
+			}
+			if platform.Compute.Memory != nil {
+				template.Spec.Template.Spec.Domain.Memory = &kubevirtv1.Memory{Guest: platform.Compute.Memory}
+			}
+		}
+	}
+
+	if platform.RootVolume != nil && platform.RootVolume.Persistent != nil && platform.RootVolume.Persistent.Size != nil {
+		template.Spec.DataVolumeTemplates[0].Spec.Storage = &v1beta1.StorageSpec{
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceStorage: *platform.RootVolume.Persistent.Size,
+				},
+			},
+		}
+	}
+
+	if networkInterfaceMultiQueueRequested(platform) {
+		template.Spec.Template.Spec.Domain.Devices.NetworkInterfaceMultiQueue = pointer.Bool(true)
+	}
+
+	applyPerformanceProfile(&template.Spec.Template.Spec.Domain, platform.PerformanceProfile)
+
+	return template
+}
+
+// applyPerformanceProfile sets the DomainSpec fields that back a NodePool's
+// KubevirtPerformanceProfile: dedicated CPU placement and NUMA passthrough on CPU, hugepages on
+// Memory, and the domain's IOThreadsPolicy. Per-disk BlockMultiQueue/DedicatedIOThread are set by
+// buildDisks, since they're read off the same profile before the Domain is assembled.
+func applyPerformanceProfile(domain *kubevirtv1.DomainSpec, profile *hyperv1.KubevirtPerformanceProfile) {
+	if profile == nil {
+		return
+	}
+
+	if profile.DedicatedCPUPlacement || profile.IsolateEmulatorThread || profile.NUMA != nil {
+		if domain.CPU == nil {
+			domain.CPU = &kubevirtv1.CPU{}
+		}
+		domain.CPU.DedicatedCPUPlacement = profile.DedicatedCPUPlacement
+		domain.CPU.IsolateEmulatorThread = profile.IsolateEmulatorThread
+		if profile.NUMA != nil && profile.NUMA.GuestMappingPassthrough {
+			domain.CPU.NUMA = &kubevirtv1.NUMA{GuestMappingPassthrough: &kubevirtv1.NUMAGuestMappingPassthrough{}}
+		}
+	}
+
+	if profile.Hugepages != nil && profile.Hugepages.PageSize != "" {
+		if domain.Memory == nil {
+			domain.Memory = &kubevirtv1.Memory{}
+		}
+		domain.Memory.Hugepages = &kubevirtv1.Hugepages{PageSize: profile.Hugepages.PageSize}
+	}
+
+	if profile.IOThreadsPolicy != "" {
+		policy := kubevirtv1.IOThreadsPolicy(profile.IOThreadsPolicy)
+		domain.IOThreadsPolicy = &policy
+	}
+}
+
+// bootVolumeDataVolumeSpec returns the per-VM DataVolumeTemplate spec that clones the boot
+// volume from the active source: a fixed PVC in pinned mode, or the DataImportCron's managed
+// DataSource when the NodePool is tracking a rolling channel.
+func bootVolumeDataVolumeSpec(bootImage *cachedBootImage) v1beta1.DataVolumeSpec {
+	if bootImage.dataSourceName != "" {
+		namespace := bootImage.namespace
+		return v1beta1.DataVolumeSpec{
+			SourceRef: &v1beta1.DataVolumeSourceRef{
+				Kind:      "DataSource",
+				Namespace: &namespace,
+				Name:      bootImage.dataSourceName,
+			},
+		}
+	}
+
+	return v1beta1.DataVolumeSpec{
+		Source: &v1beta1.DataVolumeSource{
+			PVC: &v1beta1.DataVolumeSourcePVC{
+				Namespace: bootImage.namespace,
+				Name:      bootImage.dvName,
+			},
+		},
+	}
+}
+
+// buildDisks returns the root volume disk, stamped with BlockMultiQueue and a dedicated IO
+// thread when the NodePool's performance profile requests block-device multi-queueing.
+func buildDisks(profile *hyperv1.KubevirtPerformanceProfile) []kubevirtv1.Disk {
+	disk := kubevirtv1.Disk{
+		Name: rootVolumeName,
+		DiskDevice: kubevirtv1.DiskDevice{
+			Disk: &kubevirtv1.DiskTarget{
+				Bus: rootVolumeDiskBus,
+			},
+		},
+	}
+
+	if profile != nil && profile.BlockMultiQueue {
+		disk.BlockMultiQueue = pointer.Bool(true)
+		disk.DedicatedIOThread = pointer.Bool(true)
+	}
+
+	return []kubevirtv1.Disk{disk}
+}
+
+// buildInterfaces returns the pod-network interface (unless DefaultNetwork is disabled)
+// followed by one interface per AdditionalNetworks entry, in the order they were declared.
+func buildInterfaces(platform *hyperv1.KubevirtNodePoolPlatform) []kubevirtv1.Interface {
+	var interfaces []kubevirtv1.Interface
+	if attachesDefaultNetwork(platform) {
+		interfaces = append(interfaces, kubevirtv1.Interface{
+			Name: defaultNetworkName,
+			InterfaceBindingMethod: kubevirtv1.InterfaceBindingMethod{
+				Bridge: &kubevirtv1.InterfaceBridge{},
+			},
+		})
+	}
+
+	for _, network := range platform.AdditionalNetworks {
+		interfaces = append(interfaces, kubevirtv1.Interface{
+			Name:                   network.Name,
+			MacAddress:             network.MAC,
+			Model:                  network.Model,
+			InterfaceBindingMethod: bindingMethodFor(network.BindingMethod),
+		})
+	}
+
+	return interfaces
+}
+
+// networkInterfaceMultiQueueRequested reports whether any configuration on platform calls for
+// KubeVirt's multi-queue virtio networking. KubeVirt only exposes this as a domain-wide toggle
+// (Devices.NetworkInterfaceMultiQueue), so a per-AdditionalNetworks MultiQueue request enables it
+// for every non-SR-IOV interface on the VM, not just the one it was set on.
+func networkInterfaceMultiQueueRequested(platform *hyperv1.KubevirtNodePoolPlatform) bool {
+	if platform.NetworkInterfaceMultiQueue != nil && *platform.NetworkInterfaceMultiQueue == hyperv1.MultiQueueEnable {
+		return true
+	}
+	for _, network := range platform.AdditionalNetworks {
+		if network.MultiQueue {
+			return true
+		}
+	}
+	return false
+}
+
+func bindingMethodFor(method hyperv1.KubevirtNetworkBindingMethod) kubevirtv1.InterfaceBindingMethod {
+	switch method {
+	case hyperv1.KubevirtNetworkBindingSRIOV:
+		return kubevirtv1.InterfaceBindingMethod{SRIOV: &kubevirtv1.InterfaceSRIOV{}}
+	case hyperv1.KubevirtNetworkBindingMasquerade:
+		return kubevirtv1.InterfaceBindingMethod{Masquerade: &kubevirtv1.InterfaceMasquerade{}}
+	case hyperv1.KubevirtNetworkBindingPasst:
+		return kubevirtv1.InterfaceBindingMethod{Passt: &kubevirtv1.InterfacePasst{}}
+	case hyperv1.KubevirtNetworkBindingBridge:
+		fallthrough
+	default:
+		return kubevirtv1.InterfaceBindingMethod{Bridge: &kubevirtv1.InterfaceBridge{}}
+	}
+}
+
+// buildNetworks returns the pod network (unless DefaultNetwork is disabled) followed by
+// one Multus network per AdditionalNetworks entry.
+func buildNetworks(platform *hyperv1.KubevirtNodePoolPlatform) []kubevirtv1.Network {
+	var networks []kubevirtv1.Network
+	if attachesDefaultNetwork(platform) {
+		networks = append(networks, kubevirtv1.Network{
+			Name: defaultNetworkName,
+			NetworkSource: kubevirtv1.NetworkSource{
+				Pod: &kubevirtv1.PodNetwork{},
+			},
+		})
+	}
+
+	for _, network := range platform.AdditionalNetworks {
+		networks = append(networks, kubevirtv1.Network{
+			Name: network.Name,
+			NetworkSource: kubevirtv1.NetworkSource{
+				Multus: &kubevirtv1.MultusNetwork{
+					NetworkName: network.NetworkAttachmentDefinition,
+				},
+			},
+		})
+	}
+
+	return networks
+}
+
+func attachesDefaultNetwork(platform *hyperv1.KubevirtNodePoolPlatform) bool {
+	return platform.DefaultNetwork == nil || *platform.DefaultNetwork
+}
+
+// specHashInput is the canonical, order-independent projection of a KubevirtNodePoolPlatform
+// that SpecHash hashes over. Any field here that changes on a running VM requires a rolling
+// replacement to take effect.
+type specHashInput struct {
+	Cores                      *uint32  `json:"cores,omitempty"`
+	Memory                     *string  `json:"memory,omitempty"`
+	QosClass                   *string  `json:"qosClass,omitempty"`
+	MemoryRequest              *string  `json:"memoryRequest,omitempty"`
+	MemoryLimit                *string  `json:"memoryLimit,omitempty"`
+	CPURequest                 *uint32  `json:"cpuRequest,omitempty"`
+	CPULimit                   *uint32  `json:"cpuLimit,omitempty"`
+	RootVolumeSize             *string  `json:"rootVolumeSize,omitempty"`
+	RootVolumeStorageClass     *string  `json:"rootVolumeStorageClass,omitempty"`
+	NetworkInterfaceMultiQueue *string  `json:"networkInterfaceMultiQueue,omitempty"`
+	DefaultNetwork             *bool    `json:"defaultNetwork,omitempty"`
+	AdditionalNetworks         []string `json:"additionalNetworks,omitempty"`
+	PerformanceProfile         *string  `json:"performanceProfile,omitempty"`
+}
+
+// SpecHash computes a stable hash over the subset of a KubevirtNodePoolPlatform that feeds into
+// MachineTemplateSpec: changing any of it on a running VM is silently ignored by KubeVirt, so a
+// drift-detection controller diffs this hash against the live VM's annotation to decide whether
+// to roll the machine.
+func SpecHash(platform *hyperv1.KubevirtNodePoolPlatform) string {
+	input := specHashInput{}
+
+	if platform.Compute != nil {
+		input.Cores = platform.Compute.Cores
+		if platform.Compute.Memory != nil {
+			s := platform.Compute.Memory.String()
+			input.Memory = &s
+		}
+		if platform.Compute.QosClass != nil {
+			s := string(*platform.Compute.QosClass)
+			input.QosClass = &s
+		}
+		if platform.Compute.MemoryRequest != nil {
+			s := platform.Compute.MemoryRequest.String()
+			input.MemoryRequest = &s
+		}
+		if platform.Compute.MemoryLimit != nil {
+			s := platform.Compute.MemoryLimit.String()
+			input.MemoryLimit = &s
+		}
+		input.CPURequest = platform.Compute.CPURequest
+		input.CPULimit = platform.Compute.CPULimit
+	}
+
+	if platform.RootVolume != nil && platform.RootVolume.Persistent != nil {
+		if platform.RootVolume.Persistent.Size != nil {
+			s := platform.RootVolume.Persistent.Size.String()
+			input.RootVolumeSize = &s
+		}
+		input.RootVolumeStorageClass = platform.RootVolume.Persistent.StorageClass
+	}
+
+	if platform.NetworkInterfaceMultiQueue != nil {
+		s := string(*platform.NetworkInterfaceMultiQueue)
+		input.NetworkInterfaceMultiQueue = &s
+	}
+
+	input.DefaultNetwork = platform.DefaultNetwork
+
+	for _, network := range platform.AdditionalNetworks {
+		input.AdditionalNetworks = append(input.AdditionalNetworks, fmt.Sprintf(
+			"%s|%s|%s|%s|%s|%t", network.Name, network.NetworkAttachmentDefinition, network.BindingMethod, network.MAC, network.Model, network.MultiQueue,
+		))
+	}
+	sort.Strings(input.AdditionalNetworks)
+
+	if profile := platform.PerformanceProfile; profile != nil {
+		numaPassthrough := profile.NUMA != nil && profile.NUMA.GuestMappingPassthrough
+		hugepagesSize := ""
+		if profile.Hugepages != nil {
+			hugepagesSize = profile.Hugepages.PageSize
+		}
+		s := fmt.Sprintf("%t|%t|%t|%s|%s|%t", profile.DedicatedCPUPlacement, profile.IsolateEmulatorThread, numaPassthrough, hugepagesSize, profile.IOThreadsPolicy, profile.BlockMultiQueue)
+		input.PerformanceProfile = &s
+	}
+
+	// json.Marshal on a struct with fixed field order is deterministic regardless of the order
+	// fields were set in, which is what makes this hash stable under field reordering.
+	raw, err := json.Marshal(input)
+	if err != nil {
+		// Marshaling a plain value struct of strings/bools/slices never fails.
+		panic(fmt.Sprintf("failed to marshal kubevirt spec hash input: %v", err))
+	}
+
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])[:32]
+}
+
+// PlatformValidation validates the KubeVirt-specific fields of a NodePool's platform spec.
+func PlatformValidation(nodePool *hyperv1.NodePool) error {
+	platform := nodePool.Spec.Platform.Kubevirt
+	if platform == nil {
+		return fmt.Errorf("the kubevirt platform spec must not be nil")
+	}
+
+	if !attachesDefaultNetwork(platform) && len(platform.AdditionalNetworks) == 0 {
+		return fmt.Errorf("DefaultNetwork is disabled but no AdditionalNetworks were supplied; the node would have no network attachments")
+	}
+
+	if platform.RootVolume != nil && platform.RootVolume.Image != nil && platform.RootVolume.Image.AutoUpdate != nil {
+		autoUpdate := platform.RootVolume.Image.AutoUpdate
+		if autoUpdate.Schedule == "" {
+			return fmt.Errorf("RootVolume.Image.AutoUpdate.Schedule must be set")
+		}
+		if autoUpdate.KeepLast < 0 {
+			return fmt.Errorf("RootVolume.Image.AutoUpdate.KeepLast must not be negative")
+		}
+	}
+
+	seenNames := map[string]bool{}
+	for _, network := range platform.AdditionalNetworks {
+		if network.Name == "" {
+			return fmt.Errorf("additional network entries must have a non-empty Name")
+		}
+		if seenNames[network.Name] {
+			return fmt.Errorf("duplicate additional network name %q", network.Name)
+		}
+		seenNames[network.Name] = true
+
+		if network.NetworkAttachmentDefinition == "" {
+			return fmt.Errorf("additional network %q must set NetworkAttachmentDefinition", network.Name)
+		}
+
+		switch network.BindingMethod {
+		case hyperv1.KubevirtNetworkBindingBridge, hyperv1.KubevirtNetworkBindingSRIOV, hyperv1.KubevirtNetworkBindingMasquerade, hyperv1.KubevirtNetworkBindingPasst:
+		case "":
+			return fmt.Errorf("additional network %q must set a BindingMethod", network.Name)
+		default:
+			return fmt.Errorf("additional network %q has unsupported BindingMethod %q", network.Name, network.BindingMethod)
+		}
+
+		if network.BindingMethod == hyperv1.KubevirtNetworkBindingSRIOV && network.MultiQueue {
+			return fmt.Errorf("additional network %q: MultiQueue is not supported with the SR-IOV binding method", network.Name)
+		}
+	}
+
+	if platform.Compute != nil && platform.Compute.QosClass != nil && *platform.Compute.QosClass == hyperv1.QoSClassBurstable {
+		if platform.Compute.MemoryLimit == nil && platform.Compute.CPULimit == nil {
+			return fmt.Errorf("Compute.QosClass Burstable requires at least one of Compute.MemoryLimit or Compute.CPULimit, or the VMI would land in the BestEffort QoS class")
+		}
+	}
+
+	if platform.PerformanceProfile != nil {
+		profile := platform.PerformanceProfile
+
+		if profile.DedicatedCPUPlacement {
+			if platform.Compute == nil || platform.Compute.QosClass == nil || *platform.Compute.QosClass != hyperv1.QoSClassGuaranteed {
+				return fmt.Errorf("PerformanceProfile.DedicatedCPUPlacement requires Compute.QosClass to be Guaranteed")
+			}
+		}
+
+		if profile.Hugepages != nil && profile.Hugepages.PageSize != "" {
+			pageSize, err := apiresource.ParseQuantity(profile.Hugepages.PageSize)
+			if err != nil {
+				return fmt.Errorf("PerformanceProfile.Hugepages.PageSize %q is not a valid quantity: %w", profile.Hugepages.PageSize, err)
+			}
+			if pageSize.Sign() <= 0 {
+				return fmt.Errorf("PerformanceProfile.Hugepages.PageSize must be positive")
+			}
+			if platform.Compute == nil || platform.Compute.Memory == nil {
+				return fmt.Errorf("PerformanceProfile.Hugepages requires Compute.Memory to be set")
+			}
+			if platform.Compute.Memory.Value()%pageSize.Value() != 0 {
+				return fmt.Errorf("Compute.Memory %s is not a multiple of PerformanceProfile.Hugepages.PageSize %s", platform.Compute.Memory.String(), profile.Hugepages.PageSize)
+			}
+		}
+	}
+
+	return nil
+}
@@ -13,6 +13,7 @@ import (
 	suppconfig "github.com/openshift/hypershift/support/config"
 	"go.uber.org/zap/zaptest"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	apiresource "k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -137,6 +138,57 @@ func TestKubevirtMachineTemplate(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "happy flow - QoS Class Burstable",
+			nodePool: &hyperv1.NodePool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      poolName,
+					Namespace: namespace,
+				},
+				Spec: hyperv1.NodePoolSpec{
+					ClusterName: clusterName,
+					Replicas:    nil,
+					Config:      nil,
+					Management:  hyperv1.NodePoolManagement{},
+					AutoScaling: nil,
+					Platform: hyperv1.NodePoolPlatform{
+						Type: hyperv1.KubevirtPlatform,
+						Kubevirt: generateKubevirtPlatform(
+							memoryNPOption("5Gi"),
+							coresNPOption(4),
+							imageNPOption("testimage"),
+							volumeNPOption("32Gi"),
+							qosClassBurstableNPOption(),
+							memoryRequestNPOption("2Gi"),
+							cpuRequestNPOption(2),
+							memoryLimitNPOption("5Gi"),
+							cpuLimitNPOption(4),
+						),
+					},
+					Release: hyperv1.Release{},
+				},
+			},
+			hcluster: &hyperv1.HostedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "my-hostedcluster",
+					Namespace: "clusters",
+				},
+				Spec: hyperv1.HostedClusterSpec{
+					InfraID: "1234",
+				},
+			},
+
+			expected: &capikubevirt.KubevirtMachineTemplateSpec{
+				Template: capikubevirt.KubevirtMachineTemplateResource{
+					Spec: capikubevirt.KubevirtMachineSpec{
+						VirtualMachineTemplate: *generateNodeTemplate(
+							storageTmpltOpt("32Gi"),
+							burstableResourcesOpt(4, 2, "5Gi", "2Gi"),
+						),
+					},
+				},
+			},
+		},
 		{
 			name: "NetworkInterfaceMultiQueue is Disable",
 			nodePool: &hyperv1.NodePool{
@@ -194,58 +246,583 @@ func TestKubevirtMachineTemplate(t *testing.T) {
 				},
 				Spec: hyperv1.NodePoolSpec{
 					ClusterName: clusterName,
-					Replicas:    nil,
-					Config:      nil,
-					Management:  hyperv1.NodePoolManagement{},
-					AutoScaling: nil,
+					Replicas:    nil,
+					Config:      nil,
+					Management:  hyperv1.NodePoolManagement{},
+					AutoScaling: nil,
+					Platform: hyperv1.NodePoolPlatform{
+						Type: hyperv1.KubevirtPlatform,
+						Kubevirt: generateKubevirtPlatform(
+							memoryNPOption("5Gi"),
+							coresNPOption(4),
+							imageNPOption("testimage"),
+							volumeNPOption("32Gi"),
+							multiQueueNPOption(hyperv1.MultiQueueEnable),
+						),
+					},
+					Release: hyperv1.Release{},
+				},
+			},
+			hcluster: &hyperv1.HostedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "my-hostedcluster",
+					Namespace: "clusters",
+				},
+				Spec: hyperv1.HostedClusterSpec{
+					InfraID: "1234",
+				},
+			},
+
+			expected: &capikubevirt.KubevirtMachineTemplateSpec{
+				Template: capikubevirt.KubevirtMachineTemplateResource{
+					Spec: capikubevirt.KubevirtMachineSpec{
+						VirtualMachineTemplate: *generateNodeTemplate(
+							memoryTmpltOpt("5Gi"),
+							cpuTmpltOpt(4),
+							storageTmpltOpt("32Gi"),
+							networkInterfaceMultiQueueTmpltOpt(),
+						),
+					},
+				},
+			},
+		},
+		{
+			name: "AdditionalNetworks - pod network plus multus",
+			nodePool: &hyperv1.NodePool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      poolName,
+					Namespace: namespace,
+				},
+				Spec: hyperv1.NodePoolSpec{
+					ClusterName: clusterName,
+					Management:  hyperv1.NodePoolManagement{},
+					Platform: hyperv1.NodePoolPlatform{
+						Type: hyperv1.KubevirtPlatform,
+						Kubevirt: generateKubevirtPlatform(
+							memoryNPOption("5Gi"),
+							coresNPOption(4),
+							imageNPOption("testimage"),
+							volumeNPOption("32Gi"),
+							additionalNetworksNPOption(hyperv1.KubevirtNetworkAttachment{
+								Name:                        "secondary",
+								NetworkAttachmentDefinition: "my-ns/secondary-net",
+								BindingMethod:               hyperv1.KubevirtNetworkBindingBridge,
+							}),
+						),
+					},
+					Release: hyperv1.Release{},
+				},
+			},
+			hcluster: &hyperv1.HostedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "my-hostedcluster",
+					Namespace: "clusters",
+				},
+				Spec: hyperv1.HostedClusterSpec{
+					InfraID: "1234",
+				},
+			},
+
+			expected: &capikubevirt.KubevirtMachineTemplateSpec{
+				Template: capikubevirt.KubevirtMachineTemplateResource{
+					Spec: capikubevirt.KubevirtMachineSpec{
+						VirtualMachineTemplate: *generateNodeTemplate(
+							memoryTmpltOpt("5Gi"),
+							cpuTmpltOpt(4),
+							storageTmpltOpt("32Gi"),
+							additionalNetworkTmpltOpt("secondary", "my-ns/secondary-net", kubevirtv1.InterfaceBindingMethod{Bridge: &kubevirtv1.InterfaceBridge{}}),
+						),
+					},
+				},
+			},
+		},
+		{
+			name: "AdditionalNetworks - multus only, pod network disabled",
+			nodePool: &hyperv1.NodePool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      poolName,
+					Namespace: namespace,
+				},
+				Spec: hyperv1.NodePoolSpec{
+					ClusterName: clusterName,
+					Management:  hyperv1.NodePoolManagement{},
+					Platform: hyperv1.NodePoolPlatform{
+						Type: hyperv1.KubevirtPlatform,
+						Kubevirt: generateKubevirtPlatform(
+							memoryNPOption("5Gi"),
+							coresNPOption(4),
+							imageNPOption("testimage"),
+							volumeNPOption("32Gi"),
+							defaultNetworkNPOption(false),
+							additionalNetworksNPOption(hyperv1.KubevirtNetworkAttachment{
+								Name:                        "secondary",
+								NetworkAttachmentDefinition: "my-ns/secondary-net",
+								BindingMethod:               hyperv1.KubevirtNetworkBindingMasquerade,
+							}),
+						),
+					},
+					Release: hyperv1.Release{},
+				},
+			},
+			hcluster: &hyperv1.HostedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "my-hostedcluster",
+					Namespace: "clusters",
+				},
+				Spec: hyperv1.HostedClusterSpec{
+					InfraID: "1234",
+				},
+			},
+
+			expected: &capikubevirt.KubevirtMachineTemplateSpec{
+				Template: capikubevirt.KubevirtMachineTemplateResource{
+					Spec: capikubevirt.KubevirtMachineSpec{
+						VirtualMachineTemplate: *generateNodeTemplate(
+							memoryTmpltOpt("5Gi"),
+							cpuTmpltOpt(4),
+							storageTmpltOpt("32Gi"),
+							noDefaultNetworkTmpltOpt(),
+							additionalNetworkTmpltOpt("secondary", "my-ns/secondary-net", kubevirtv1.InterfaceBindingMethod{Masquerade: &kubevirtv1.InterfaceMasquerade{}}),
+						),
+					},
+				},
+			},
+		},
+		{
+			name: "AdditionalNetworks - SR-IOV binding",
+			nodePool: &hyperv1.NodePool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      poolName,
+					Namespace: namespace,
+				},
+				Spec: hyperv1.NodePoolSpec{
+					ClusterName: clusterName,
+					Management:  hyperv1.NodePoolManagement{},
+					Platform: hyperv1.NodePoolPlatform{
+						Type: hyperv1.KubevirtPlatform,
+						Kubevirt: generateKubevirtPlatform(
+							memoryNPOption("5Gi"),
+							coresNPOption(4),
+							imageNPOption("testimage"),
+							volumeNPOption("32Gi"),
+							additionalNetworksNPOption(hyperv1.KubevirtNetworkAttachment{
+								Name:                        "sriov-net",
+								NetworkAttachmentDefinition: "my-ns/sriov-net",
+								BindingMethod:               hyperv1.KubevirtNetworkBindingSRIOV,
+							}),
+						),
+					},
+					Release: hyperv1.Release{},
+				},
+			},
+			hcluster: &hyperv1.HostedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "my-hostedcluster",
+					Namespace: "clusters",
+				},
+				Spec: hyperv1.HostedClusterSpec{
+					InfraID: "1234",
+				},
+			},
+
+			expected: &capikubevirt.KubevirtMachineTemplateSpec{
+				Template: capikubevirt.KubevirtMachineTemplateResource{
+					Spec: capikubevirt.KubevirtMachineSpec{
+						VirtualMachineTemplate: *generateNodeTemplate(
+							memoryTmpltOpt("5Gi"),
+							cpuTmpltOpt(4),
+							storageTmpltOpt("32Gi"),
+							additionalNetworkTmpltOpt("sriov-net", "my-ns/sriov-net", kubevirtv1.InterfaceBindingMethod{SRIOV: &kubevirtv1.InterfaceSRIOV{}}),
+						),
+					},
+				},
+			},
+		},
+		{
+			name: "AdditionalNetworks - MAC, Model and MultiQueue drive the domain-level flag",
+			nodePool: &hyperv1.NodePool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      poolName,
+					Namespace: namespace,
+				},
+				Spec: hyperv1.NodePoolSpec{
+					ClusterName: clusterName,
+					Management:  hyperv1.NodePoolManagement{},
+					Platform: hyperv1.NodePoolPlatform{
+						Type: hyperv1.KubevirtPlatform,
+						Kubevirt: generateKubevirtPlatform(
+							memoryNPOption("5Gi"),
+							coresNPOption(4),
+							imageNPOption("testimage"),
+							volumeNPOption("32Gi"),
+							additionalNetworksNPOption(hyperv1.KubevirtNetworkAttachment{
+								Name:                        "secondary",
+								NetworkAttachmentDefinition: "my-ns/secondary-net",
+								BindingMethod:               hyperv1.KubevirtNetworkBindingBridge,
+								MAC:                         "02:00:00:00:00:01",
+								Model:                       "e1000",
+								MultiQueue:                  true,
+							}),
+						),
+					},
+					Release: hyperv1.Release{},
+				},
+			},
+			hcluster: &hyperv1.HostedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "my-hostedcluster",
+					Namespace: "clusters",
+				},
+				Spec: hyperv1.HostedClusterSpec{
+					InfraID: "1234",
+				},
+			},
+
+			expected: &capikubevirt.KubevirtMachineTemplateSpec{
+				Template: capikubevirt.KubevirtMachineTemplateResource{
+					Spec: capikubevirt.KubevirtMachineSpec{
+						VirtualMachineTemplate: *generateNodeTemplate(
+							memoryTmpltOpt("5Gi"),
+							cpuTmpltOpt(4),
+							storageTmpltOpt("32Gi"),
+							additionalNetworkWithMacModelTmpltOpt("secondary", "my-ns/secondary-net", "02:00:00:00:00:01", "e1000", kubevirtv1.InterfaceBindingMethod{Bridge: &kubevirtv1.InterfaceBridge{}}),
+							networkInterfaceMultiQueueTmpltOpt(),
+						),
+					},
+				},
+			},
+		},
+		{
+			name: "PerformanceProfile - dedicated CPU, NUMA, hugepages, IO threads",
+			nodePool: &hyperv1.NodePool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      poolName,
+					Namespace: namespace,
+				},
+				Spec: hyperv1.NodePoolSpec{
+					ClusterName: clusterName,
+					Management:  hyperv1.NodePoolManagement{},
+					Platform: hyperv1.NodePoolPlatform{
+						Type: hyperv1.KubevirtPlatform,
+						Kubevirt: generateKubevirtPlatform(
+							memoryNPOption("8Gi"),
+							coresNPOption(4),
+							imageNPOption("testimage"),
+							volumeNPOption("32Gi"),
+							qosClassGuaranteedNPOption(),
+							performanceProfileNPOption(hyperv1.KubevirtPerformanceProfile{
+								DedicatedCPUPlacement: true,
+								IsolateEmulatorThread: true,
+								NUMA:                  &hyperv1.KubevirtNUMA{GuestMappingPassthrough: true},
+								Hugepages:             &hyperv1.KubevirtHugepages{PageSize: "1Gi"},
+								IOThreadsPolicy:       "auto",
+								BlockMultiQueue:       true,
+							}),
+						),
+					},
+					Release: hyperv1.Release{},
+				},
+			},
+			hcluster: &hyperv1.HostedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "my-hostedcluster",
+					Namespace: "clusters",
+				},
+				Spec: hyperv1.HostedClusterSpec{
+					InfraID: "1234",
+				},
+			},
+
+			expected: &capikubevirt.KubevirtMachineTemplateSpec{
+				Template: capikubevirt.KubevirtMachineTemplateResource{
+					Spec: capikubevirt.KubevirtMachineSpec{
+						VirtualMachineTemplate: *generateNodeTemplate(
+							storageTmpltOpt("32Gi"),
+							guaranteedResourcesOpt(4, "8Gi"),
+							performanceProfileTmpltOpt(),
+						),
+					},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			g.Expect(PlatformValidation(tc.nodePool)).To(Succeed())
+
+			bootImage := newCachedBootImage(bootImageName, imageHash, hostedClusterNamespace, false, nil)
+			bootImage.dvName = bootImageNamePrefix + "12345"
+			result := MachineTemplateSpec(tc.nodePool, bootImage, tc.hcluster)
+
+			// The spec-hash annotation is exercised separately by TestSpecHash and
+			// TestMachineTemplateSpecHashLocation; strip it here so this table only has to
+			// assert the shape MachineTemplateSpec produces.
+			resultAnnotations := result.Template.Spec.VirtualMachineTemplate.Spec.Template.ObjectMeta.Annotations
+			g.Expect(resultAnnotations).To(HaveKey(SpecHashAnnotation))
+			delete(resultAnnotations, SpecHashAnnotation)
+
+			topLevelAnnotations := result.Template.Spec.VirtualMachineTemplate.ObjectMeta.Annotations
+			g.Expect(topLevelAnnotations).To(HaveKey(SpecHashAnnotation))
+			delete(topLevelAnnotations, SpecHashAnnotation)
+			if len(topLevelAnnotations) == 0 {
+				// The hash is the only top-level annotation, and the expected templates leave it nil.
+				result.Template.Spec.VirtualMachineTemplate.ObjectMeta.Annotations = nil
+			}
+
+			g.Expect(result).To(Equal(tc.expected), "Comparison failed\n%v", cmp.Diff(tc.expected, result))
+		})
+	}
+}
+
+// TestMachineTemplateSpecHashLocation guards against kubevirtdrift.Reconciler reading the spec
+// hash from a VirtualMachine annotation that MachineTemplateSpec never stamps. The reconciler
+// reads the hash off the VirtualMachine's own metadata, i.e. VirtualMachineTemplate.ObjectMeta,
+// not the nested VMI template under VirtualMachineTemplate.Spec.Template.
+func TestMachineTemplateSpecHashLocation(t *testing.T) {
+	g := NewWithT(t)
+
+	nodePool := &hyperv1.NodePool{
+		ObjectMeta: metav1.ObjectMeta{Name: poolName, Namespace: namespace},
+		Spec: hyperv1.NodePoolSpec{
+			ClusterName: clusterName,
+			Platform: hyperv1.NodePoolPlatform{
+				Type: hyperv1.KubevirtPlatform,
+				Kubevirt: generateKubevirtPlatform(
+					memoryNPOption("5Gi"),
+					coresNPOption(4),
+					imageNPOption("testimage"),
+					volumeNPOption("32Gi"),
+				),
+			},
+		},
+	}
+	hcluster := &hyperv1.HostedCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-hostedcluster", Namespace: "clusters"},
+		Spec:       hyperv1.HostedClusterSpec{InfraID: "1234"},
+	}
+	bootImage := newCachedBootImage(bootImageName, imageHash, hostedClusterNamespace, false, nil)
+
+	result := MachineTemplateSpec(nodePool, bootImage, hcluster)
+	vm := result.Template.Spec.VirtualMachineTemplate
+
+	desiredHash := SpecHash(nodePool.Spec.Platform.Kubevirt)
+	g.Expect(vm.ObjectMeta.Annotations).To(HaveKeyWithValue(SpecHashAnnotation, desiredHash),
+		"the drift reconciler reads the hash off the VirtualMachine's own metadata, not the VMI template nested under Spec.Template")
+}
+
+func TestPlatformValidation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		options     []nodePoolOption
+		expectError bool
+	}{
+		{
+			name: "SR-IOV with MultiQueue requested is rejected",
+			options: []nodePoolOption{
+				additionalNetworksNPOption(hyperv1.KubevirtNetworkAttachment{
+					Name:                        "sriov-net",
+					NetworkAttachmentDefinition: "my-ns/sriov-net",
+					BindingMethod:               hyperv1.KubevirtNetworkBindingSRIOV,
+					MultiQueue:                  true,
+				}),
+			},
+			expectError: true,
+		},
+		{
+			name: "DefaultNetwork disabled with no additional networks is rejected",
+			options: []nodePoolOption{
+				defaultNetworkNPOption(false),
+			},
+			expectError: true,
+		},
+		{
+			name: "missing BindingMethod is rejected",
+			options: []nodePoolOption{
+				additionalNetworksNPOption(hyperv1.KubevirtNetworkAttachment{
+					Name:                        "secondary",
+					NetworkAttachmentDefinition: "my-ns/secondary-net",
+				}),
+			},
+			expectError: true,
+		},
+		{
+			name: "AutoUpdate with empty Schedule is rejected",
+			options: []nodePoolOption{
+				autoUpdateNPOption("", 2),
+			},
+			expectError: true,
+		},
+		{
+			name: "AutoUpdate with negative KeepLast is rejected",
+			options: []nodePoolOption{
+				autoUpdateNPOption("0 */6 * * *", -1),
+			},
+			expectError: true,
+		},
+		{
+			name: "AutoUpdate with a valid schedule is accepted",
+			options: []nodePoolOption{
+				autoUpdateNPOption("0 */6 * * *", 2),
+			},
+			expectError: false,
+		},
+		{
+			name: "DefaultNetwork disabled with an additional network is accepted",
+			options: []nodePoolOption{
+				defaultNetworkNPOption(false),
+				additionalNetworksNPOption(hyperv1.KubevirtNetworkAttachment{
+					Name:                        "secondary",
+					NetworkAttachmentDefinition: "my-ns/secondary-net",
+					BindingMethod:               hyperv1.KubevirtNetworkBindingBridge,
+				}),
+			},
+			expectError: false,
+		},
+		{
+			name: "PerformanceProfile with DedicatedCPUPlacement but no Guaranteed QoS is rejected",
+			options: []nodePoolOption{
+				performanceProfileNPOption(hyperv1.KubevirtPerformanceProfile{DedicatedCPUPlacement: true}),
+			},
+			expectError: true,
+		},
+		{
+			name: "PerformanceProfile with DedicatedCPUPlacement and Guaranteed QoS is accepted",
+			options: []nodePoolOption{
+				qosClassGuaranteedNPOption(),
+				performanceProfileNPOption(hyperv1.KubevirtPerformanceProfile{DedicatedCPUPlacement: true}),
+			},
+			expectError: false,
+		},
+		{
+			name: "PerformanceProfile with Compute.Memory not a multiple of Hugepages.PageSize is rejected",
+			options: []nodePoolOption{
+				performanceProfileNPOption(hyperv1.KubevirtPerformanceProfile{Hugepages: &hyperv1.KubevirtHugepages{PageSize: "3Gi"}}),
+			},
+			expectError: true,
+		},
+		{
+			name: "PerformanceProfile with Hugepages.PageSize dividing Compute.Memory evenly is accepted",
+			options: []nodePoolOption{
+				performanceProfileNPOption(hyperv1.KubevirtPerformanceProfile{Hugepages: &hyperv1.KubevirtHugepages{PageSize: "1Gi"}}),
+			},
+			expectError: false,
+		},
+		{
+			name: "Burstable QoS with no MemoryLimit or CPULimit is rejected",
+			options: []nodePoolOption{
+				qosClassBurstableNPOption(),
+			},
+			expectError: true,
+		},
+		{
+			name: "Burstable QoS with a MemoryLimit is accepted",
+			options: []nodePoolOption{
+				qosClassBurstableNPOption(),
+				memoryLimitNPOption("8Gi"),
+			},
+			expectError: false,
+		},
+		{
+			name: "Burstable QoS with a CPULimit is accepted",
+			options: []nodePoolOption{
+				qosClassBurstableNPOption(),
+				cpuLimitNPOption(8),
+			},
+			expectError: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			nodePool := &hyperv1.NodePool{
+				ObjectMeta: metav1.ObjectMeta{Name: poolName, Namespace: namespace},
+				Spec: hyperv1.NodePoolSpec{
+					ClusterName: clusterName,
 					Platform: hyperv1.NodePoolPlatform{
 						Type: hyperv1.KubevirtPlatform,
-						Kubevirt: generateKubevirtPlatform(
+						Kubevirt: generateKubevirtPlatform(append([]nodePoolOption{
 							memoryNPOption("5Gi"),
 							coresNPOption(4),
 							imageNPOption("testimage"),
 							volumeNPOption("32Gi"),
-							multiQueueNPOption(hyperv1.MultiQueueEnable),
-						),
+						}, tc.options...)...),
 					},
-					Release: hyperv1.Release{},
-				},
-			},
-			hcluster: &hyperv1.HostedCluster{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      "my-hostedcluster",
-					Namespace: "clusters",
-				},
-				Spec: hyperv1.HostedClusterSpec{
-					InfraID: "1234",
 				},
-			},
+			}
 
-			expected: &capikubevirt.KubevirtMachineTemplateSpec{
-				Template: capikubevirt.KubevirtMachineTemplateResource{
-					Spec: capikubevirt.KubevirtMachineSpec{
-						VirtualMachineTemplate: *generateNodeTemplate(
-							memoryTmpltOpt("5Gi"),
-							cpuTmpltOpt(4),
-							storageTmpltOpt("32Gi"),
-							networkInterfaceMultiQueueTmpltOpt(),
-						),
-					},
-				},
-			},
-		},
+			err := PlatformValidation(nodePool)
+			if tc.expectError {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).ToNot(HaveOccurred())
+			}
+		})
 	}
+}
 
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
-			g := NewWithT(t)
+func TestSpecHash(t *testing.T) {
+	base := func() *hyperv1.KubevirtNodePoolPlatform {
+		return generateKubevirtPlatform(
+			memoryNPOption("5Gi"),
+			coresNPOption(4),
+			imageNPOption("testimage"),
+			volumeNPOption("32Gi"),
+			additionalNetworksNPOption(
+				hyperv1.KubevirtNetworkAttachment{Name: "a", NetworkAttachmentDefinition: "ns/a", BindingMethod: hyperv1.KubevirtNetworkBindingBridge},
+				hyperv1.KubevirtNetworkAttachment{Name: "b", NetworkAttachmentDefinition: "ns/b", BindingMethod: hyperv1.KubevirtNetworkBindingSRIOV},
+			),
+		)
+	}
 
-			g.Expect(PlatformValidation(tc.nodePool)).To(Succeed())
+	t.Run("stable under AdditionalNetworks reordering", func(t *testing.T) {
+		g := NewWithT(t)
+		reordered := generateKubevirtPlatform(
+			memoryNPOption("5Gi"),
+			coresNPOption(4),
+			imageNPOption("testimage"),
+			volumeNPOption("32Gi"),
+			additionalNetworksNPOption(
+				hyperv1.KubevirtNetworkAttachment{Name: "b", NetworkAttachmentDefinition: "ns/b", BindingMethod: hyperv1.KubevirtNetworkBindingSRIOV},
+				hyperv1.KubevirtNetworkAttachment{Name: "a", NetworkAttachmentDefinition: "ns/a", BindingMethod: hyperv1.KubevirtNetworkBindingBridge},
+			),
+		)
+		g.Expect(SpecHash(reordered)).To(Equal(SpecHash(base())))
+	})
 
-			bootImage := newCachedBootImage(bootImageName, imageHash, hostedClusterNamespace, false)
-			bootImage.dvName = bootImageNamePrefix + "12345"
-			result := MachineTemplateSpec(tc.nodePool, bootImage, tc.hcluster)
-			g.Expect(result).To(Equal(tc.expected), "Comparison failed\n%v", cmp.Diff(tc.expected, result))
+	t.Run("stable across repeated calls", func(t *testing.T) {
+		g := NewWithT(t)
+		g.Expect(SpecHash(base())).To(Equal(SpecHash(base())))
+	})
+
+	mutations := []struct {
+		name   string
+		mutate func(*hyperv1.KubevirtNodePoolPlatform)
+	}{
+		{"cores", func(p *hyperv1.KubevirtNodePoolPlatform) { cores := uint32(8); p.Compute.Cores = &cores }},
+		{"memory", func(p *hyperv1.KubevirtNodePoolPlatform) { m := apiresource.MustParse("6Gi"); p.Compute.Memory = &m }},
+		{"qos class", func(p *hyperv1.KubevirtNodePoolPlatform) { q := hyperv1.QoSClassGuaranteed; p.Compute.QosClass = &q }},
+		{"memory request", func(p *hyperv1.KubevirtNodePoolPlatform) { m := apiresource.MustParse("2Gi"); p.Compute.MemoryRequest = &m }},
+		{"memory limit", func(p *hyperv1.KubevirtNodePoolPlatform) { m := apiresource.MustParse("6Gi"); p.Compute.MemoryLimit = &m }},
+		{"cpu request", func(p *hyperv1.KubevirtNodePoolPlatform) { c := uint32(2); p.Compute.CPURequest = &c }},
+		{"cpu limit", func(p *hyperv1.KubevirtNodePoolPlatform) { c := uint32(8); p.Compute.CPULimit = &c }},
+		{"root volume size", func(p *hyperv1.KubevirtNodePoolPlatform) { s := apiresource.MustParse("64Gi"); p.RootVolume.Persistent.Size = &s }},
+		{"root volume storage class", func(p *hyperv1.KubevirtNodePoolPlatform) { sc := "fast"; p.RootVolume.Persistent.StorageClass = &sc }},
+		{"additional network added", func(p *hyperv1.KubevirtNodePoolPlatform) {
+			p.AdditionalNetworks = append(p.AdditionalNetworks, hyperv1.KubevirtNetworkAttachment{Name: "c", NetworkAttachmentDefinition: "ns/c", BindingMethod: hyperv1.KubevirtNetworkBindingMasquerade})
+		}},
+		{"default network toggled", func(p *hyperv1.KubevirtNodePoolPlatform) { f := false; p.DefaultNetwork = &f }},
+	}
+
+	for _, m := range mutations {
+		t.Run("sensitive to "+m.name, func(t *testing.T) {
+			g := NewWithT(t)
+			mutated := base()
+			m.mutate(mutated)
+			g.Expect(SpecHash(mutated)).ToNot(Equal(SpecHash(base())))
 		})
 	}
 }
@@ -282,6 +859,7 @@ func TestCacheImage(t *testing.T) {
 		asserFunc         func(Gomega, []v1beta1.DataVolume, string, *cachedBootImage)
 		errExpected       bool
 		dvNamePrefix      string
+		extraLabels       map[string]string
 	}{
 		{
 			name:         "happy flow - no existing PVC",
@@ -368,6 +946,41 @@ func TestCacheImage(t *testing.T) {
 				}
 			},
 		},
+		{
+			name:         "foreign labels - should not adopt or clean up a DV missing the required tenant labels",
+			nodePool:     nodePool,
+			errExpected:  false,
+			extraLabels:  map[string]string{"hypershift.openshift.io/infra-cluster-id": "infra-cluster-1"},
+			dvNamePrefix: bootImageNamePrefix,
+			existingResources: []client.Object{
+				&v1beta1.DataVolume{
+					ObjectMeta: metav1.ObjectMeta{
+						GenerateName: bootImageNamePrefix,
+						Name:         bootImageNamePrefix + "other-tenant",
+						Namespace:    hostedClusterNamespace,
+						Annotations: map[string]string{
+							bootImageDVAnnotationHash: imageHash,
+						},
+						Labels: map[string]string{
+							bootImageDVLabelRoleName: bootImageDVLabelRoleValue,
+							bootImageDVLabelUID:      infraId,
+							// Missing the operator-required "infra-cluster-id" label: this DV
+							// belongs to a different tenant sharing the same infra-id/hash.
+						},
+					},
+				},
+			},
+			asserFunc: func(g Gomega, dvs []v1beta1.DataVolume, expectedDVNamePrefix string, bootImage *cachedBootImage) {
+				g.ExpectWithOffset(1, dvs).Should(HaveLen(2), "the foreign DV must be left alone and a new one created for this tenant")
+				for _, dv := range dvs {
+					if dv.Name != bootImageNamePrefix+"other-tenant" {
+						g.ExpectWithOffset(1, dv.Name).Should(HavePrefix(expectedDVNamePrefix))
+						g.ExpectWithOffset(1, bootImage.dvName).Should(Equal(dv.Name))
+						g.ExpectWithOffset(1, dv.Labels).Should(HaveKeyWithValue("hypershift.openshift.io/infra-cluster-id", "infra-cluster-1"))
+					}
+				}
+			},
+		},
 	}
 
 	ctx := logr.NewContext(context.Background(), zapr.NewLogger(zaptest.NewLogger(t)))
@@ -379,7 +992,7 @@ func TestCacheImage(t *testing.T) {
 			_ = v1beta1.AddToScheme(scheme)
 			cl := fake.NewClientBuilder().WithScheme(scheme).WithObjects(tc.existingResources...).Build()
 
-			bootImage := newCachedBootImage(bootImageName, imageHash, hostedClusterNamespace, false)
+			bootImage := newCachedBootImage(bootImageName, imageHash, hostedClusterNamespace, false, tc.extraLabels)
 			err := bootImage.CacheImage(ctx, cl, tc.nodePool, infraId)
 
 			if tc.errExpected != (err != nil) {
@@ -397,6 +1010,137 @@ func TestCacheImage(t *testing.T) {
 	}
 }
 
+func TestCacheImageAutoUpdate(t *testing.T) {
+	schedule := "0 */6 * * *"
+	nodePool := &hyperv1.NodePool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      poolName,
+			Namespace: namespace,
+		},
+		Spec: hyperv1.NodePoolSpec{
+			ClusterName: clusterName,
+			Platform: hyperv1.NodePoolPlatform{
+				Type: hyperv1.KubevirtPlatform,
+				Kubevirt: generateKubevirtPlatform(
+					memoryNPOption("5Gi"),
+					coresNPOption(4),
+					imageNPOption("testimage"),
+					volumeNPOption("32Gi"),
+					autoUpdateNPOption(schedule, 3),
+				),
+			},
+		},
+	}
+
+	ctx := logr.NewContext(context.Background(), zapr.NewLogger(zaptest.NewLogger(t)))
+	g := NewWithT(t)
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	_ = v1beta1.AddToScheme(scheme)
+	cl := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	bootImage := newCachedBootImage(bootImageName, imageHash, hostedClusterNamespace, true, nil)
+	g.Expect(bootImage.CacheImage(ctx, cl, nodePool, infraId)).To(Succeed())
+	g.Expect(bootImage.dvName).To(BeEmpty(), "pinned DataVolume must not be created in auto-update mode")
+	g.Expect(bootImage.dataSourceName).ToNot(BeEmpty())
+	g.Expect(bootImage.ActiveSourceName()).To(Equal(bootImage.dataSourceName))
+
+	cron := &v1beta1.DataImportCron{}
+	g.Expect(cl.Get(ctx, client.ObjectKey{Namespace: hostedClusterNamespace, Name: bootImage.dataSourceName}, cron)).To(Succeed())
+	g.Expect(cron.Spec.Schedule).To(Equal(schedule))
+	g.Expect(*cron.Spec.ImportsToKeep).To(Equal(int32(3)))
+	g.Expect(cron.Spec.ManagedDataSource).To(Equal(bootImage.dataSourceName))
+
+	// reconciling again must update the existing cron in place rather than failing on AlreadyExists.
+	g.Expect(bootImage.CacheImage(ctx, cl, nodePool, infraId)).To(Succeed())
+
+	dvs := v1beta1.DataVolumeList{}
+	g.Expect(cl.List(ctx, &dvs)).To(Succeed())
+	g.Expect(dvs.Items).To(BeEmpty(), "auto-update mode must never create a pinned DataVolume")
+}
+
+func TestCacheImageModeTransition(t *testing.T) {
+	schedule := "0 */6 * * *"
+	nodePool := &hyperv1.NodePool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      poolName,
+			Namespace: namespace,
+		},
+		Spec: hyperv1.NodePoolSpec{
+			ClusterName: clusterName,
+			Platform: hyperv1.NodePoolPlatform{
+				Type: hyperv1.KubevirtPlatform,
+				Kubevirt: generateKubevirtPlatform(
+					memoryNPOption("5Gi"),
+					coresNPOption(4),
+					imageNPOption("testimage"),
+					volumeNPOption("32Gi"),
+					autoUpdateNPOption(schedule, 3),
+				),
+			},
+		},
+	}
+
+	ctx := logr.NewContext(context.Background(), zapr.NewLogger(zaptest.NewLogger(t)))
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	_ = v1beta1.AddToScheme(scheme)
+	cl := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	t.Run("pinned to auto-update deletes the pinned DataVolume", func(tst *testing.T) {
+		g := NewWithT(tst)
+
+		pinned := newCachedBootImage(bootImageName, imageHash, hostedClusterNamespace, false, nil)
+		g.Expect(pinned.CacheImage(ctx, cl, nodePool, infraId)).To(Succeed())
+		g.Expect(pinned.dvName).ToNot(BeEmpty())
+
+		autoUpdate := newCachedBootImage(bootImageName, imageHash, hostedClusterNamespace, true, nil)
+		g.Expect(autoUpdate.CacheImage(ctx, cl, nodePool, infraId)).To(Succeed())
+
+		dvs := v1beta1.DataVolumeList{}
+		g.Expect(cl.List(ctx, &dvs)).To(Succeed())
+		g.Expect(dvs.Items).To(BeEmpty(), "the pinned DataVolume left over from pinned mode must be cleaned up")
+	})
+
+	t.Run("auto-update back to pinned deletes the DataImportCron", func(tst *testing.T) {
+		g := NewWithT(tst)
+
+		cronName := dataImportCronNamePrefix + nodePool.Name
+		pinned := newCachedBootImage(bootImageName, imageHash, hostedClusterNamespace, false, nil)
+		g.Expect(pinned.CacheImage(ctx, cl, nodePool, infraId)).To(Succeed())
+
+		cron := &v1beta1.DataImportCron{}
+		err := cl.Get(ctx, client.ObjectKey{Namespace: hostedClusterNamespace, Name: cronName}, cron)
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue(), "the DataImportCron left over from auto-update mode must be cleaned up")
+	})
+}
+
+func TestCacheImageAutoUpdateNilRootVolume(t *testing.T) {
+	g := NewWithT(t)
+	nodePool := &hyperv1.NodePool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      poolName,
+			Namespace: namespace,
+		},
+		Spec: hyperv1.NodePoolSpec{
+			ClusterName: clusterName,
+			Platform: hyperv1.NodePoolPlatform{
+				Type:     hyperv1.KubevirtPlatform,
+				Kubevirt: &hyperv1.KubevirtNodePoolPlatform{},
+			},
+		},
+	}
+
+	ctx := logr.NewContext(context.Background(), zapr.NewLogger(zaptest.NewLogger(t)))
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	_ = v1beta1.AddToScheme(scheme)
+	cl := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	bootImage := newCachedBootImage(bootImageName, imageHash, hostedClusterNamespace, true, nil)
+	g.Expect(bootImage.CacheImage(ctx, cl, nodePool, infraId)).To(MatchError(ContainSubstring("RootVolume.Image.AutoUpdate is nil")))
+}
+
 func assertDV(g Gomega, dvs []v1beta1.DataVolume, expectedDVNamePrefix string, bootImage *cachedBootImage) {
 	g.ExpectWithOffset(1, dvs).Should(HaveLen(1), "failed to read the DataVolume back; No matched DataVolume")
 	g.ExpectWithOffset(1, dvs[0].Name).Should(HavePrefix(expectedDVNamePrefix))
@@ -437,6 +1181,59 @@ func qosClassGuaranteedNPOption() nodePoolOption {
 	}
 }
 
+func memoryRequestNPOption(memoryRequest string) nodePoolOption {
+	return func(kvNodePool *hyperv1.KubevirtNodePoolPlatform) {
+		if kvNodePool.Compute == nil {
+			kvNodePool.Compute = &hyperv1.KubevirtCompute{}
+		}
+
+		memoryRequestQuantity := apiresource.MustParse(memoryRequest)
+		kvNodePool.Compute.MemoryRequest = &memoryRequestQuantity
+	}
+}
+
+func cpuRequestNPOption(cpuRequest uint32) nodePoolOption {
+	return func(kvNodePool *hyperv1.KubevirtNodePoolPlatform) {
+		if kvNodePool.Compute == nil {
+			kvNodePool.Compute = &hyperv1.KubevirtCompute{}
+		}
+
+		kvNodePool.Compute.CPURequest = &cpuRequest
+	}
+}
+
+func qosClassBurstableNPOption() nodePoolOption {
+	return func(kvNodePool *hyperv1.KubevirtNodePoolPlatform) {
+		if kvNodePool.Compute == nil {
+			kvNodePool.Compute = &hyperv1.KubevirtCompute{}
+		}
+
+		qosClassBurstable := hyperv1.QoSClassBurstable
+		kvNodePool.Compute.QosClass = &qosClassBurstable
+	}
+}
+
+func memoryLimitNPOption(memoryLimit string) nodePoolOption {
+	return func(kvNodePool *hyperv1.KubevirtNodePoolPlatform) {
+		if kvNodePool.Compute == nil {
+			kvNodePool.Compute = &hyperv1.KubevirtCompute{}
+		}
+
+		memoryLimitQuantity := apiresource.MustParse(memoryLimit)
+		kvNodePool.Compute.MemoryLimit = &memoryLimitQuantity
+	}
+}
+
+func cpuLimitNPOption(cpuLimit uint32) nodePoolOption {
+	return func(kvNodePool *hyperv1.KubevirtNodePoolPlatform) {
+		if kvNodePool.Compute == nil {
+			kvNodePool.Compute = &hyperv1.KubevirtCompute{}
+		}
+
+		kvNodePool.Compute.CPULimit = &cpuLimit
+	}
+}
+
 func imageNPOption(image string) nodePoolOption {
 	return func(kvNodePool *hyperv1.KubevirtNodePoolPlatform) {
 		if kvNodePool.RootVolume == nil {
@@ -473,6 +1270,39 @@ func multiQueueNPOption(multiQueue hyperv1.MultiQueueSetting) nodePoolOption {
 	}
 }
 
+func additionalNetworksNPOption(networks ...hyperv1.KubevirtNetworkAttachment) nodePoolOption {
+	return func(kvNodePool *hyperv1.KubevirtNodePoolPlatform) {
+		kvNodePool.AdditionalNetworks = append(kvNodePool.AdditionalNetworks, networks...)
+	}
+}
+
+func defaultNetworkNPOption(attach bool) nodePoolOption {
+	return func(kvNodePool *hyperv1.KubevirtNodePoolPlatform) {
+		kvNodePool.DefaultNetwork = &attach
+	}
+}
+
+func autoUpdateNPOption(schedule string, keepLast int32) nodePoolOption {
+	return func(kvNodePool *hyperv1.KubevirtNodePoolPlatform) {
+		if kvNodePool.RootVolume == nil {
+			kvNodePool.RootVolume = &hyperv1.KubevirtRootVolume{}
+		}
+		if kvNodePool.RootVolume.Image == nil {
+			kvNodePool.RootVolume.Image = &hyperv1.KubevirtDiskImage{}
+		}
+		kvNodePool.RootVolume.Image.AutoUpdate = &hyperv1.KubevirtImageAutoUpdate{
+			Schedule: schedule,
+			KeepLast: keepLast,
+		}
+	}
+}
+
+func performanceProfileNPOption(profile hyperv1.KubevirtPerformanceProfile) nodePoolOption {
+	return func(kvNodePool *hyperv1.KubevirtNodePoolPlatform) {
+		kvNodePool.PerformanceProfile = &profile
+	}
+}
+
 func generateKubevirtPlatform(options ...nodePoolOption) *hyperv1.KubevirtNodePoolPlatform {
 	exampleTemplate := &hyperv1.KubevirtNodePoolPlatform{}
 
@@ -521,6 +1351,76 @@ func networkInterfaceMultiQueueTmpltOpt() nodeTemplateOption {
 	}
 }
 
+// performanceProfileTmpltOpt mirrors the DomainSpec wiring applyPerformanceProfile/buildDisks
+// produce for the PerformanceProfile set up in the "PerformanceProfile" test case above.
+func performanceProfileTmpltOpt() nodeTemplateOption {
+	return func(template *capikubevirt.VirtualMachineTemplateSpec) {
+		domain := &template.Spec.Template.Spec.Domain
+		if domain.CPU == nil {
+			domain.CPU = &kubevirtv1.CPU{}
+		}
+		domain.CPU.DedicatedCPUPlacement = true
+		domain.CPU.IsolateEmulatorThread = true
+		domain.CPU.NUMA = &kubevirtv1.NUMA{GuestMappingPassthrough: &kubevirtv1.NUMAGuestMappingPassthrough{}}
+
+		if domain.Memory == nil {
+			domain.Memory = &kubevirtv1.Memory{}
+		}
+		domain.Memory.Hugepages = &kubevirtv1.Hugepages{PageSize: "1Gi"}
+
+		policy := kubevirtv1.IOThreadsPolicy("auto")
+		domain.IOThreadsPolicy = &policy
+
+		for i := range domain.Devices.Disks {
+			domain.Devices.Disks[i].BlockMultiQueue = pointer.Bool(true)
+			domain.Devices.Disks[i].DedicatedIOThread = pointer.Bool(true)
+		}
+	}
+}
+
+func noDefaultNetworkTmpltOpt() nodeTemplateOption {
+	return func(template *capikubevirt.VirtualMachineTemplateSpec) {
+		template.Spec.Template.Spec.Networks = nil
+		template.Spec.Template.Spec.Domain.Devices.Interfaces = nil
+	}
+}
+
+func additionalNetworkTmpltOpt(name, netAttachDef string, bindingMethod kubevirtv1.InterfaceBindingMethod) nodeTemplateOption {
+	return func(template *capikubevirt.VirtualMachineTemplateSpec) {
+		template.Spec.Template.Spec.Domain.Devices.Interfaces = append(template.Spec.Template.Spec.Domain.Devices.Interfaces, kubevirtv1.Interface{
+			Name:                   name,
+			InterfaceBindingMethod: bindingMethod,
+		})
+		template.Spec.Template.Spec.Networks = append(template.Spec.Template.Spec.Networks, kubevirtv1.Network{
+			Name: name,
+			NetworkSource: kubevirtv1.NetworkSource{
+				Multus: &kubevirtv1.MultusNetwork{
+					NetworkName: netAttachDef,
+				},
+			},
+		})
+	}
+}
+
+func additionalNetworkWithMacModelTmpltOpt(name, netAttachDef, mac, model string, bindingMethod kubevirtv1.InterfaceBindingMethod) nodeTemplateOption {
+	return func(template *capikubevirt.VirtualMachineTemplateSpec) {
+		template.Spec.Template.Spec.Domain.Devices.Interfaces = append(template.Spec.Template.Spec.Domain.Devices.Interfaces, kubevirtv1.Interface{
+			Name:                   name,
+			MacAddress:             mac,
+			Model:                  model,
+			InterfaceBindingMethod: bindingMethod,
+		})
+		template.Spec.Template.Spec.Networks = append(template.Spec.Template.Spec.Networks, kubevirtv1.Network{
+			Name: name,
+			NetworkSource: kubevirtv1.NetworkSource{
+				Multus: &kubevirtv1.MultusNetwork{
+					NetworkName: netAttachDef,
+				},
+			},
+		})
+	}
+}
+
 func guaranteedResourcesOpt(cores uint32, memory string) nodeTemplateOption {
 	memReq := apiresource.MustParse(memory)
 	coresReq := *apiresource.NewQuantity(int64(cores), apiresource.DecimalSI)
@@ -542,6 +1442,25 @@ func guaranteedResourcesOpt(cores uint32, memory string) nodeTemplateOption {
 	}
 }
 
+func burstableResourcesOpt(cores uint32, cpuRequest uint32, memory, memoryRequest string) nodeTemplateOption {
+	memReq := apiresource.MustParse(memoryRequest)
+	memLimit := apiresource.MustParse(memory)
+	cpuReq := *apiresource.NewQuantity(int64(cpuRequest), apiresource.DecimalSI)
+	cpuLimit := *apiresource.NewQuantity(int64(cores), apiresource.DecimalSI)
+
+	return func(template *capikubevirt.VirtualMachineTemplateSpec) {
+		template.Spec.Template.Spec.Domain.CPU = &kubevirtv1.CPU{Cores: cores}
+		template.Spec.Template.Spec.Domain.Resources.Requests = corev1.ResourceList{
+			corev1.ResourceMemory: memReq,
+			corev1.ResourceCPU:    cpuReq,
+		}
+		template.Spec.Template.Spec.Domain.Resources.Limits = corev1.ResourceList{
+			corev1.ResourceMemory: memLimit,
+			corev1.ResourceCPU:    cpuLimit,
+		}
+	}
+}
+
 func generateNodeTemplate(options ...nodeTemplateOption) *capikubevirt.VirtualMachineTemplateSpec {
 	runAlways := kubevirtv1.RunStrategyAlways
 
@@ -559,6 +1478,10 @@ func generateNodeTemplate(options ...nodeTemplateOption) *capikubevirt.VirtualMa
 					TypeMeta: metav1.TypeMeta{},
 					ObjectMeta: metav1.ObjectMeta{
 						Name: "rhcos",
+						Labels: map[string]string{
+							bootImageDVLabelRoleName: bootImageDVLabelRoleValue,
+							bootImageDVLabelUID:      "1234",
+						},
 					},
 					Spec: v1beta1.DataVolumeSpec{
 						Source: &v1beta1.DataVolumeSource{
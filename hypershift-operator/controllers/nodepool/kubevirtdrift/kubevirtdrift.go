@@ -0,0 +1,130 @@
+// Package kubevirtdrift detects KubeVirt NodePool spec drift on already-running VirtualMachines
+// and rolls them one generation at a time, borrowing the drift model Karpenter uses for NodeClaims.
+package kubevirtdrift
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	capikubevirt "sigs.k8s.io/cluster-api-provider-kubevirt/api/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	hyperv1 "github.com/openshift/hypershift/api/v1beta1"
+	"github.com/openshift/hypershift/hypershift-operator/controllers/nodepool/kubevirt"
+)
+
+// DriftedConditionType marks a KubevirtMachine whose live VirtualMachine no longer matches the
+// NodePool's desired KubeVirt platform spec.
+const DriftedConditionType capiv1.ConditionType = "Drifted"
+
+// defaultMaxUnavailable is the rolling budget used when the NodePool doesn't configure one
+// explicitly: replace drifted machines strictly one at a time.
+const defaultMaxUnavailable = 1
+
+// Reconciler rolls KubevirtMachines one generation at a time when their VirtualMachine has
+// drifted from the NodePool's desired spec.
+type Reconciler struct {
+	client.Client
+}
+
+// ReconcileDrift lists the KubevirtMachines owned by nodePool, marks any whose VirtualMachine
+// no longer carries the current spec hash as Drifted, and deletes up to the NodePool's
+// MaxUnavailable budget of them so CAPI recreates them from the latest MachineTemplate. It
+// returns the number of machines it deleted in this pass.
+func (r *Reconciler) ReconcileDrift(ctx context.Context, nodePool *hyperv1.NodePool) (int, error) {
+	desiredHash := kubevirt.SpecHash(nodePool.Spec.Platform.Kubevirt)
+
+	machineList := &capikubevirt.KubevirtMachineList{}
+	if err := r.List(ctx, machineList, client.InNamespace(nodePool.Namespace), client.MatchingLabels{
+		hyperv1.NodePoolNameLabel: nodePool.Name,
+	}); err != nil {
+		return 0, fmt.Errorf("failed to list KubevirtMachines: %w", err)
+	}
+
+	var drifted []*capikubevirt.KubevirtMachine
+	for i := range machineList.Items {
+		machine := &machineList.Items[i]
+
+		vm := &kubevirtv1.VirtualMachine{}
+		if err := r.Get(ctx, client.ObjectKeyFromObject(machine), vm); err != nil {
+			if apierrors.IsNotFound(err) {
+				// The VM hasn't been created yet by CAPI; nothing to compare against.
+				continue
+			}
+			return 0, fmt.Errorf("failed to get VirtualMachine %s/%s: %w", machine.Namespace, machine.Name, err)
+		}
+
+		if vm.Annotations[kubevirt.SpecHashAnnotation] == desiredHash {
+			continue
+		}
+
+		if !hasDriftedCondition(machine) {
+			markDrifted(machine)
+			if err := r.Status().Update(ctx, machine); err != nil {
+				return 0, fmt.Errorf("failed to mark KubevirtMachine %s/%s as drifted: %w", machine.Namespace, machine.Name, err)
+			}
+		}
+		drifted = append(drifted, machine)
+	}
+
+	if len(drifted) == 0 {
+		return 0, nil
+	}
+
+	// Deterministic ordering so repeated reconciles make steady progress through the same set.
+	sort.Slice(drifted, func(i, j int) bool { return drifted[i].Name < drifted[j].Name })
+
+	budget := maxUnavailable(nodePool, len(machineList.Items))
+	if budget > len(drifted) {
+		budget = len(drifted)
+	}
+
+	for _, machine := range drifted[:budget] {
+		if err := r.Delete(ctx, machine); err != nil && !apierrors.IsNotFound(err) {
+			return 0, fmt.Errorf("failed to delete drifted KubevirtMachine %s/%s: %w", machine.Namespace, machine.Name, err)
+		}
+	}
+
+	return budget, nil
+}
+
+func hasDriftedCondition(machine *capikubevirt.KubevirtMachine) bool {
+	for _, c := range machine.Status.Conditions {
+		if c.Type == DriftedConditionType {
+			return true
+		}
+	}
+	return false
+}
+
+func markDrifted(machine *capikubevirt.KubevirtMachine) {
+	machine.Status.Conditions = append(machine.Status.Conditions, capiv1.Condition{
+		Type:               DriftedConditionType,
+		Status:             corev1.ConditionTrue,
+		Reason:             "KubevirtSpecDrifted",
+		Message:            "NodePool KubeVirt platform spec changed; the running VirtualMachine no longer matches it",
+		LastTransitionTime: metav1.Now(),
+	})
+}
+
+// maxUnavailable resolves the NodePool's rolling-replace budget for drifted machines, falling
+// back to one at a time when the NodePool doesn't set Management.Replace.RollingUpdate.
+func maxUnavailable(nodePool *hyperv1.NodePool, total int) int {
+	replace := nodePool.Spec.Management.Replace
+	if nodePool.Spec.Management.UpgradeType != hyperv1.UpgradeTypeReplace || replace == nil || replace.RollingUpdate == nil || replace.RollingUpdate.MaxUnavailable == nil {
+		return defaultMaxUnavailable
+	}
+
+	value, err := intstr.GetScaledValueFromIntOrPercent(replace.RollingUpdate.MaxUnavailable, total, true)
+	if err != nil || value < 1 {
+		return defaultMaxUnavailable
+	}
+	return value
+}
@@ -0,0 +1,132 @@
+package kubevirtdrift
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+	capikubevirt "sigs.k8s.io/cluster-api-provider-kubevirt/api/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	hyperv1 "github.com/openshift/hypershift/api/v1beta1"
+	"github.com/openshift/hypershift/hypershift-operator/controllers/nodepool/kubevirt"
+)
+
+const (
+	namespace = "clusters-my-cluster"
+	poolName  = "my-pool"
+)
+
+func newNodePool(maxUnavailable *intstr.IntOrString) *hyperv1.NodePool {
+	cores := uint32(4)
+	return &hyperv1.NodePool{
+		ObjectMeta: metav1.ObjectMeta{Name: poolName, Namespace: namespace},
+		Spec: hyperv1.NodePoolSpec{
+			Management: hyperv1.NodePoolManagement{
+				UpgradeType: hyperv1.UpgradeTypeReplace,
+				Replace: &hyperv1.ReplaceUpgrade{
+					Strategy: hyperv1.UpgradeStrategyRollingUpdate,
+					RollingUpdate: &hyperv1.RollingUpdate{
+						MaxUnavailable: maxUnavailable,
+					},
+				},
+			},
+			Platform: hyperv1.NodePoolPlatform{
+				Type: hyperv1.KubevirtPlatform,
+				Kubevirt: &hyperv1.KubevirtNodePoolPlatform{
+					Compute: &hyperv1.KubevirtCompute{Cores: &cores},
+				},
+			},
+		},
+	}
+}
+
+func newMachine(name, hash string) (*capikubevirt.KubevirtMachine, *kubevirtv1.VirtualMachine) {
+	labels := map[string]string{hyperv1.NodePoolNameLabel: poolName}
+	machine := &capikubevirt.KubevirtMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels},
+	}
+	vm := &kubevirtv1.VirtualMachine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Annotations: map[string]string{kubevirt.SpecHashAnnotation: hash},
+		},
+	}
+	return machine, vm
+}
+
+func TestReconcileDrift(t *testing.T) {
+	g := NewWithT(t)
+
+	nodePool := newNodePool(&intstr.IntOrString{Type: intstr.Int, IntVal: 1})
+	desiredHash := kubevirt.SpecHash(nodePool.Spec.Platform.Kubevirt)
+
+	upToDateMachine, upToDateVM := newMachine("machine-uptodate", desiredHash)
+	drifted1Machine, drifted1VM := newMachine("machine-drifted-1", "stale-hash")
+	drifted2Machine, drifted2VM := newMachine("machine-drifted-2", "stale-hash")
+
+	scheme := runtime.NewScheme()
+	g.Expect(capikubevirt.AddToScheme(scheme)).To(Succeed())
+	g.Expect(kubevirtv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+
+	cl := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithStatusSubresource(&capikubevirt.KubevirtMachine{}).
+		WithObjects(upToDateMachine, drifted1Machine, drifted2Machine, upToDateVM, drifted1VM, drifted2VM).
+		Build()
+
+	r := &Reconciler{Client: cl}
+
+	deleted, err := r.ReconcileDrift(context.Background(), nodePool)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(deleted).To(Equal(1), "MaxUnavailable=1 must remove exactly one drifted machine per pass")
+
+	machines := &capikubevirt.KubevirtMachineList{}
+	g.Expect(cl.List(context.Background(), machines, client.InNamespace(namespace))).To(Succeed())
+	g.Expect(machines.Items).To(HaveLen(2), "one drifted machine should have been deleted, leaving the up-to-date one and one drifted one")
+
+	var remainingDrifted *capikubevirt.KubevirtMachine
+	for i := range machines.Items {
+		if machines.Items[i].Name != upToDateMachine.Name {
+			remainingDrifted = &machines.Items[i]
+		}
+	}
+	g.Expect(remainingDrifted).ToNot(BeNil())
+	g.Expect(hasDriftedCondition(remainingDrifted)).To(BeTrue(), "the surviving drifted machine must be marked Drifted even though it wasn't deleted yet")
+
+	// A second pass should finish rolling the remaining drifted machine.
+	deleted, err = r.ReconcileDrift(context.Background(), nodePool)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(deleted).To(Equal(1))
+
+	g.Expect(cl.List(context.Background(), machines, client.InNamespace(namespace))).To(Succeed())
+	g.Expect(machines.Items).To(HaveLen(1))
+	g.Expect(machines.Items[0].Name).To(Equal(upToDateMachine.Name))
+}
+
+func TestReconcileDriftNoDrift(t *testing.T) {
+	g := NewWithT(t)
+
+	nodePool := newNodePool(nil)
+	desiredHash := kubevirt.SpecHash(nodePool.Spec.Platform.Kubevirt)
+	machine, vm := newMachine("machine-uptodate", desiredHash)
+
+	scheme := runtime.NewScheme()
+	g.Expect(capikubevirt.AddToScheme(scheme)).To(Succeed())
+	g.Expect(kubevirtv1.AddToScheme(scheme)).To(Succeed())
+
+	cl := fake.NewClientBuilder().WithScheme(scheme).WithObjects(machine, vm).Build()
+	r := &Reconciler{Client: cl}
+
+	deleted, err := r.ReconcileDrift(context.Background(), nodePool)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(deleted).To(Equal(0))
+}